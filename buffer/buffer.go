@@ -2,6 +2,8 @@ package buffer
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 )
 
@@ -10,16 +12,55 @@ type Cursor struct {
 }
 
 type Brush struct {
-	FG     Color
-	BG     Color
-	Blink  bool
-	Invert bool
-	Bold   bool
+	FG        Color
+	BG        Color
+	Blink     bool
+	Invert    bool
+	Bold      bool
+	Underline bool
+	// Protected characters can't be erased by the selective erase (DECSERA)
+	Protected bool
 }
 
 type BrushedRune struct {
 	R     rune
 	Brush Brush
+	// Link references the hyperlink that the character is part of, 0 means no link
+	Link int
+}
+
+// LineAttr controls whether the line is rendered with single or double sized characters
+// https://vt100.net/docs/vt510-rm/DECDHL.html
+type LineAttr int
+
+const (
+	LineSingleWidth LineAttr = iota
+	// LineDoubleWidth characters are twice as wide, the line can contain only half of the columns
+	LineDoubleWidth
+	// LineDoubleHeightTop is the top half of double width and double height characters
+	LineDoubleHeightTop
+	// LineDoubleHeightBottom is the bottom half of double width and double height characters
+	LineDoubleHeightBottom
+)
+
+type line struct {
+	cells []BrushedRune
+	attr  LineAttr
+	// marks are the shell integration marks (OSC 133)
+	marks LineMarks
+	// wrapped is true when the text continues on the next line because it didn't fit on this one
+	wrapped bool
+}
+
+// savedCursor is the cursor state saved by DECSC (Save Cursor) and restored by DECRC (Restore Cursor)
+// https://vt100.net/docs/vt510-rm/DECSC.html
+type savedCursor struct {
+	cursor         Cursor
+	brush          Brush
+	originMode     bool
+	nextWriteWraps bool
+	charsets       [4]Charset
+	gl             int
 }
 
 type bufferType int
@@ -30,12 +71,21 @@ const (
 )
 
 type Buffer struct {
-	lines          [][]BrushedRune
-	alternateLines [][]BrushedRune
+	lines          []line
+	alternateLines []line
 	bufferType     bufferType
 	size           BufferSize
 	cursor         Cursor
-	savedCursor    Cursor
+	cursorStyle    CursorStyle
+	// defaultCursorStyle is the style configured by the user, programs reset the cursor to it
+	defaultCursorStyle CursorStyle
+	// cursorVisible is false when the program hid the cursor
+	cursorVisible bool
+	// savedCursor is the cursor state saved on the current screen
+	savedCursor savedCursor
+	// alternateSavedCursor is the cursor state saved on the inactive screen,
+	// it gets swapped with savedCursor the same way as lines and alternateLines
+	alternateSavedCursor savedCursor
 	// nextWriteWraps indicates whether the next WriteRune will start on the new line.
 	// if true, then before writing the next rune, we'll execute CR LF
 	//
@@ -47,6 +97,13 @@ type Buffer struct {
 	scrollAreaStart int
 	// scrollAreaEnd is the index of the last line + 1 that will scroll (it's one larger, same as len(slice))
 	scrollAreaEnd int
+	// leftRightMarginMode enables setting the left and right margins (DECLRMM)
+	// https://vt100.net/docs/vt510-rm/DECLRMM.html
+	leftRightMarginMode bool
+	// scrollAreaLeft is the index of the first column that will scroll
+	scrollAreaLeft int
+	// scrollAreaRight is the index of the last column + 1 that will scroll
+	scrollAreaRight int
 	// originMode controls where the cursor can be placed with relationship to the scrolling region (margins)
 	// false - the origin is at the upper-left character position on the screen. Line and column numbers are, therefore, independent of current margin settings. The cursor may be positioned outside the margins with a cursor position (CUP) or horizontal and vertical position (HVP) control.
 	//
@@ -54,6 +111,42 @@ type Buffer struct {
 	// described in https://vt100.net/docs/vt100-ug/chapter3.html
 	originMode bool
 	brush      Brush
+	// tabStops marks the columns where the horizontal tab (HT) stops the cursor
+	tabStops []bool
+	// charsets are the character sets designated into the G0, G1, G2 and G3 slots
+	charsets [4]Charset
+	// gl is the index of the charset (0-3) used for printing characters, it's changed by locking shifts (SO, SI)
+	gl int
+	// singleShift is the index of the charset (2 or 3) used for printing the next character, 0 means no single shift
+	singleShift int
+	// insertMode shifts the characters right of the cursor instead of replacing them (IRM)
+	insertMode bool
+	// rectAttributeChange makes ChangeRectAttributes change only the rectangle instead of a stream of characters
+	rectAttributeChange bool
+	// scrollback contains lines that scrolled off the top of the primary screen, the last line is the most recent
+	scrollback []line
+	// maxScrollback is the maximum number of lines in the scrollback
+	maxScrollback int
+	// viewOffset is the number of lines the view is scrolled up into the scrollback, 0 shows the screen
+	viewOffset int
+	// links stores hyperlinks referenced by the characters on the screens and in the scrollback
+	links linkTable
+	// currentLink is the reference to the link that WriteRune adds to characters, 0 means no link
+	currentLink int
+	// palette translates the character colors to RGB
+	palette Palette
+	// theme is the palette that programs can reset the colors to
+	theme Palette
+	// synchronizedOutput is true while the program updates the screen and it shouldn't be rendered (mode 2026)
+	synchronizedOutput bool
+	// focusReporting is true when the program wants to know when the window gains or loses focus (mode 1004)
+	focusReporting bool
+	// keyboardFlags is the stack of the kitty keyboard protocol flags of the current screen, the top is the last element
+	keyboardFlags []KeyboardFlags
+	// alternateKeyboardFlags is the stack of the other screen, every screen has its own stack
+	alternateKeyboardFlags []KeyboardFlags
+	// damage tracks the lines that changed since the GUI last rendered them
+	damage damage
 }
 
 type BufferSize struct {
@@ -63,38 +156,193 @@ type BufferSize struct {
 
 func New(cols, rows int) *Buffer {
 	size := BufferSize{Rows: rows, Cols: cols}
-	buffer := &Buffer{size: size}
+	buffer := &Buffer{
+		size:               size,
+		cursorStyle:        DefaultCursorStyle,
+		defaultCursorStyle: DefaultCursorStyle,
+		cursorVisible:      true,
+		maxScrollback:      DefaultScrollback,
+		links:              newLinkTable(),
+		palette:            DefaultPalette(),
+		theme:              DefaultPalette(),
+		damage:             newDamage(rows),
+	}
 	buffer.ResetBrush()
+	buffer.savedCursor = buffer.defaultSavedCursor()
+	buffer.alternateSavedCursor = buffer.defaultSavedCursor()
 	buffer.lines = buffer.makeNewLines(size)
 	buffer.alternateLines = buffer.makeNewLines(size)
 	buffer.resetScrollArea()
+	buffer.resetTabStops()
 	return buffer
 }
 
+// Reset puts the buffer into its power-on state. Both screens are cleared
+// and the brush, tab stops, modes, charsets and scroll area are set to their default values.
+// The scrollback is cleared, but its maximum size stays the same. The palette resets to the theme
+// and the cursor style to the default style.
+// RIS - Reset to Initial State https://vt100.net/docs/vt510-rm/RIS.html
+func (b *Buffer) Reset() {
+	maxScrollback, theme, cursorStyle := b.maxScrollback, b.theme, b.defaultCursorStyle
+	*b = *New(b.size.Cols, b.size.Rows)
+	b.maxScrollback = maxScrollback
+	b.SetTheme(theme)
+	b.SetDefaultCursorStyle(cursorStyle)
+}
+
+// Clone returns a copy of the buffer that doesn't change when the buffer changes
+// the lines in the scrollback share characters with the buffer, because characters don't change after they scroll off the screen
+func (b *Buffer) Clone() *Buffer {
+	c := *b
+	c.lines = cloneLines(b.lines)
+	c.alternateLines = cloneLines(b.alternateLines)
+	c.scrollback = slices.Clone(b.scrollback)
+	c.tabStops = slices.Clone(b.tabStops)
+	c.keyboardFlags = slices.Clone(b.keyboardFlags)
+	c.alternateKeyboardFlags = slices.Clone(b.alternateKeyboardFlags)
+	c.damage.lines = slices.Clone(b.damage.lines)
+	c.links = linkTable{links: maps.Clone(b.links.links), refs: maps.Clone(b.links.refs), next: b.links.next}
+	return &c
+}
+
+func cloneLines(lines []line) []line {
+	cloned := make([]line, len(lines))
+	for i, l := range lines {
+		cloned[i] = l
+		cloned[i].cells = slices.Clone(l.cells)
+	}
+	return cloned
+}
+
+// SoftReset sets the modes, margins, brush, charsets and saved cursor to their default
+// values, but keeps the screen content and the cursor position.
+// DECSTR - Soft Terminal Reset https://vt100.net/docs/vt510-rm/DECSTR.html
+func (b *Buffer) SoftReset() {
+	b.cursorVisible = true
+	b.originMode = false
+	b.insertMode = false
+	b.rectAttributeChange = false
+	b.resetScrollArea()
+	b.brush = Brush{}
+	b.charsets = [4]Charset{}
+	b.gl = 0
+	b.singleShift = 0
+	b.savedCursor = b.defaultSavedCursor()
+}
+
+// CR moves the cursor to the left margin, or to the first column if the cursor is left of the left margin
 func (b *Buffer) CR() {
-	b.SetCursor(0, b.cursor.Y)
+	x := 0
+	if b.cursor.X >= b.scrollAreaLeft || b.originMode {
+		x = b.scrollAreaLeft
+	}
+	b.SetCursor(x, b.cursor.Y)
 }
+
+// LF moves the cursor one line down, if the cursor is on the bottom margin, the scroll area scrolls up instead
 func (b *Buffer) LF() {
 	b.nextWriteWraps = false
-	b.cursor.Y++
-	if b.cursor.Y >= b.scrollAreaEnd {
-		b.ScrollUp(1)
-		b.cursor.Y--
+	if b.cursor.Y == b.scrollAreaEnd-1 {
+		if b.cursorInLeftRightMargins() {
+			b.ScrollUp(1)
+		}
+		return
+	}
+	if b.cursor.Y < b.size.Rows-1 {
+		b.cursor.Y++
+		b.cursor.X = min(b.cursor.X, b.lineCols(b.cursor.Y)-1)
 	}
 }
 
+// ScrollUp moves the content of the scroll area n lines up
+// if the scroll area starts at the top of the screen, the lines that scroll off go to the scrollback
+// SU - Scroll Up https://vt100.net/docs/vt510-rm/SU.html
 func (b *Buffer) ScrollUp(n int) {
-	for i := b.scrollAreaStart + n; i < b.scrollAreaEnd; i++ {
-		b.lines[i-n] = b.lines[i]
+	if b.scrollAreaStart == 0 && b.fullWidthScrollArea() {
+		b.pushScrollback(b.lines[:clamp(n, 0, b.scrollAreaEnd)])
+	}
+	b.scrollRegionUp(b.scrollAreaStart, b.scrollAreaEnd, n)
+}
+
+// ScrollDown moves the content of the scroll area n lines down
+// SD - Scroll Down https://vt100.net/docs/vt510-rm/SD.html
+func (b *Buffer) ScrollDown(n int) {
+	b.scrollRegionDown(b.scrollAreaStart, b.scrollAreaEnd, n)
+}
+
+// scrollRegionUp moves lines between top and bottom (exclusive) n lines up
+// and fills the freed lines at the bottom with empty characters.
+// Only the columns between the left and right margins move.
+func (b *Buffer) scrollRegionUp(top, bottom, n int) {
+	n = clamp(n, 0, bottom-top)
+	if b.fullWidthScrollArea() {
+		copy(b.lines[top:], b.lines[top+n:bottom])
+		for i := bottom - n; i < bottom; i++ {
+			b.lines[i] = b.newLine(b.size.Cols)
+		}
+		b.damageLines(top, bottom)
+		return
+	}
+	b.damageLines(top, bottom)
+	for i := top; i < bottom-n; i++ {
+		copy(b.lines[i].cells[b.scrollAreaLeft:b.scrollAreaRight], b.lines[i+n].cells[b.scrollAreaLeft:b.scrollAreaRight])
 	}
-	for i := b.scrollAreaEnd - n; i < b.scrollAreaEnd; i++ {
-		b.lines[i] = b.newLine(b.size.Cols)
+	for i := bottom - n; i < bottom; i++ {
+		b.clearCells(i, b.scrollAreaLeft, b.scrollAreaRight)
 	}
 }
 
-// TODO maybe remove in favour of SetBrush(Brush{})
+// scrollRegionDown moves lines between top and bottom (exclusive) n lines down
+// and fills the freed lines at the top with empty characters.
+// Only the columns between the left and right margins move.
+func (b *Buffer) scrollRegionDown(top, bottom, n int) {
+	n = clamp(n, 0, bottom-top)
+	if b.fullWidthScrollArea() {
+		copy(b.lines[top+n:bottom], b.lines[top:])
+		for i := top; i < top+n; i++ {
+			b.lines[i] = b.newLine(b.size.Cols)
+		}
+		b.damageLines(top, bottom)
+		return
+	}
+	b.damageLines(top, bottom)
+	for i := bottom - 1; i >= top+n; i-- {
+		copy(b.lines[i].cells[b.scrollAreaLeft:b.scrollAreaRight], b.lines[i-n].cells[b.scrollAreaLeft:b.scrollAreaRight])
+	}
+	for i := top; i < top+n; i++ {
+		b.clearCells(i, b.scrollAreaLeft, b.scrollAreaRight)
+	}
+}
+
+// clearCells replaces characters on the row between start (inclusive) and end (exclusive) with spaces
+func (b *Buffer) clearCells(row, start, end int) {
+	line := b.lines[row].cells
+	for i := start; i < end; i++ {
+		line[i] = b.MakeRune(' ')
+	}
+	b.damageLine(row)
+}
+
+// fullWidthScrollArea returns true if the left and right margins are not set
+// in that case, we can scroll by moving whole lines
+func (b *Buffer) fullWidthScrollArea() bool {
+	return b.scrollAreaLeft == 0 && b.scrollAreaRight == b.size.Cols
+}
+
+// cursorInLeftRightMargins returns true if the cursor is between the left and the right margin
+func (b *Buffer) cursorInLeftRightMargins() bool {
+	return b.cursor.X >= b.scrollAreaLeft && b.cursor.X < b.scrollAreaRight
+}
+
+// cursorInScrollArea returns true if the cursor is inside all four margins
+func (b *Buffer) cursorInScrollArea() bool {
+	return b.cursorInLeftRightMargins() && b.cursor.Y >= b.scrollAreaStart && b.cursor.Y < b.scrollAreaEnd
+}
+
+// ResetBrush resets the graphic rendition of the brush, but it keeps the
+// character protection (DECSCA) which is only changed by DECSCA itself or by a reset.
 func (b *Buffer) ResetBrush() {
-	b.brush = Brush{FG: DefaultFG, BG: DefaultBG}
+	b.brush = Brush{Protected: b.brush.Protected}
 }
 
 func (b *Buffer) Brush() Brush {
@@ -105,12 +353,17 @@ func (b *Buffer) SetBrush(br Brush) {
 	b.brush = br
 }
 
-func (b *Buffer) newLine(cols int) []BrushedRune {
-	line := make([]BrushedRune, cols)
-	for c := range line {
-		line[c] = b.MakeRune(' ')
+func (b *Buffer) newLine(cols int) line {
+	cells := make([]BrushedRune, cols)
+	for c := range cells {
+		cells[c] = b.MakeRune(' ')
 	}
-	return line
+	return line{cells: cells}
+}
+
+// ScrollArea returns the first (top) and last + 1 (bottom) row of the scroll area
+func (b *Buffer) ScrollArea() (top, bottom int) {
+	return b.scrollAreaStart, b.scrollAreaEnd
 }
 
 func (b *Buffer) SetScrollArea(start, end int) {
@@ -119,10 +372,37 @@ func (b *Buffer) SetScrollArea(start, end int) {
 	b.cursor = Cursor{X: 0, Y: b.scrollAreaStart}
 }
 
+// SetLeftRightMarginMode enables or disables the left and right margins, disabling resets the margins
+// DECLRMM - Left Right Margin Mode https://vt100.net/docs/vt510-rm/DECLRMM.html
+func (b *Buffer) SetLeftRightMarginMode(enabled bool) {
+	b.leftRightMarginMode = enabled
+	if !enabled {
+		b.scrollAreaLeft = 0
+		b.scrollAreaRight = b.size.Cols
+	}
+}
+
+func (b *Buffer) LeftRightMarginMode() bool {
+	return b.leftRightMarginMode
+}
+
+// SetLeftRightMargins sets the first (left) and last + 1 (right) column of the scroll area
+// the margins can only be set when the left and right margin mode is enabled
+// DECSLRM - Set Left and Right Margins https://vt100.net/docs/vt510-rm/DECSLRM.html
+func (b *Buffer) SetLeftRightMargins(left, right int) {
+	if !b.leftRightMarginMode {
+		return
+	}
+	b.scrollAreaLeft = clamp(left, 0, b.size.Cols-1)
+	b.scrollAreaRight = clamp(right, b.scrollAreaLeft+1, b.size.Cols)
+	b.SetCursor(b.minX(), b.minY())
+}
+
 func (b *Buffer) resetScrollArea() {
 	b.scrollAreaStart = 0
 	b.scrollAreaEnd = len(b.lines)
-
+	b.scrollAreaLeft = 0
+	b.scrollAreaRight = b.size.Cols
 }
 
 func (b *Buffer) MakeRune(r rune) BrushedRune {
@@ -136,34 +416,89 @@ func (b *Buffer) WriteRune(r rune) {
 	if b.nextWriteWraps == true {
 		b.nextWriteWraps = false
 		// soft wrap
+		b.lines[b.cursor.Y].wrapped = true
 		b.CR()
 		b.LF()
 	}
-	b.lines[b.cursor.Y][b.cursor.X] = b.MakeRune(r)
-	b.cursor.X++
-	if b.cursor.X >= b.size.Cols {
+	if b.insertMode {
+		b.InsertCharacter(1)
+	}
+	br := b.MakeRune(r)
+	br.Link = b.currentLink
+	b.lines[b.cursor.Y].cells[b.cursor.X] = br
+	b.damageLine(b.cursor.Y)
+	if b.cursor.X == b.rightEdge()-1 {
+		// the cursor stays on the last column, the next write will wrap
 		b.nextWriteWraps = true
+	} else {
+		b.cursor.X++
 	}
 }
 
-func (b *Buffer) Runes() []BrushedRune {
-	out := make([]BrushedRune, 0, b.size.Rows*b.size.Cols) // extra space for new lines
-	for ri, r := range b.lines {
-		for ci, c := range r {
-			// invert cursor every odd interval
-			if (b.cursor.X == ci) && b.cursor.Y == ri {
-				br := c.Brush
-				br.Blink = true
-				out = append(out, BrushedRune{
-					R:     c.R,
-					Brush: br,
-				})
-			} else {
-				out = append(out, c)
-			}
+// rightEdge returns the index of the last column + 1 where the cursor can write without wrapping
+// it's the right margin, unless the cursor is already right of it
+func (b *Buffer) rightEdge() int {
+	if b.cursor.X < b.scrollAreaRight {
+		return min(b.scrollAreaRight, b.lineCols(b.cursor.Y))
+	}
+	return b.lineCols(b.cursor.Y)
+}
+
+// lineCols returns the number of columns that the row can contain, double width lines contain only half of the columns
+func (b *Buffer) lineCols(row int) int {
+	if b.lines[row].attr != LineSingleWidth {
+		return max(b.size.Cols/2, 1)
+	}
+	return b.size.Cols
+}
+
+// SetLineAttr changes the size of characters on the cursor line
+// when the line becomes double width, the characters in the right half of the line are lost
+// DECSWL, DECDWL and DECDHL https://vt100.net/docs/vt510-rm/DECDWL.html
+func (b *Buffer) SetLineAttr(attr LineAttr) {
+	b.lines[b.cursor.Y].attr = attr
+	b.damageLine(b.cursor.Y)
+	cols := b.lineCols(b.cursor.Y)
+	b.clearCells(b.cursor.Y, cols, b.size.Cols)
+	b.cursor.X = min(b.cursor.X, cols-1)
+}
+
+// LineAttrs returns the attribute of every line in the view
+func (b *Buffer) LineAttrs() []LineAttr {
+	lines := b.viewLines()
+	attrs := make([]LineAttr, len(lines))
+	for i, l := range lines {
+		attrs[i] = l.attr
+	}
+	return attrs
+}
+
+// ScreenAlignment fills the screen with E, resets the margins and moves the cursor home
+// DECALN - Screen Alignment Pattern https://vt100.net/docs/vt510-rm/DECALN.html
+func (b *Buffer) ScreenAlignment() {
+	b.resetScrollArea()
+	b.originMode = false
+	for r := range b.lines {
+		b.lines[r].attr = LineSingleWidth
+		for c := range b.lines[r].cells {
+			b.lines[r].cells[c] = BrushedRune{R: 'E', Brush: Brush{}}
 		}
 	}
+	b.damageLines(0, len(b.lines))
+	b.SetCursor(0, 0)
+}
 
+// Runes returns the characters in the view, row by row
+func (b *Buffer) Runes() []BrushedRune {
+	out := make([]BrushedRune, 0, b.size.Rows*b.size.Cols)
+	for _, r := range b.viewLines() {
+		// lines in the scrollback keep the width they had before the buffer got resized
+		cells := r.cells[:min(len(r.cells), b.size.Cols)]
+		out = append(out, cells...)
+		for i := len(cells); i < b.size.Cols; i++ {
+			out = append(out, BrushedRune{R: ' ', Brush: Brush{}})
+		}
+	}
 	return out
 }
 
@@ -178,7 +513,7 @@ func (b *Buffer) Size() BufferSize {
 func (b *Buffer) String() string {
 	var sb strings.Builder
 	for _, r := range b.lines {
-		for _, c := range r {
+		for _, c := range r.cells {
 			sb.WriteRune(c.R)
 		}
 		sb.WriteRune('\n')
@@ -192,38 +527,62 @@ func (b *Buffer) ClearLines(start, end int) {
 	e := clamp(end, 0, b.size.Rows)
 
 	toClean := b.lines[s:e]
+	b.damageLines(s, e)
 	for r := range toClean {
-		for c := range toClean[r] {
-			toClean[r][c] = b.MakeRune(' ')
+		// erased lines become single width and lose their marks
+		toClean[r].attr = LineSingleWidth
+		toClean[r].marks = 0
+		toClean[r].wrapped = false
+		for c := range toClean[r].cells {
+			toClean[r].cells[c] = b.MakeRune(' ')
 		}
 	}
 }
 
 // DeleteCharacter removes n characters from cursor onwards (including the character under cursor)
-// the characters after the deleted gap are then shifted to the cursor position
+// the characters after the deleted gap (up to the right margin) are then shifted to the cursor position
+// DCH - Delete Character https://vt100.net/docs/vt510-rm/DCH.html
 func (b *Buffer) DeleteCharacter(n int) {
-	p := clamp(n, 1, b.size.Cols-b.cursor.X)
-	line := b.lines[b.cursor.Y]
-	copy(line[b.cursor.X:], line[b.cursor.X+p:])
-	for i := len(line) - p; i < len(line); i++ {
-		line[i] = b.MakeRune(' ')
+	if !b.cursorInLeftRightMargins() {
+		return
+	}
+	end := b.scrollAreaRight
+	p := clamp(n, 1, end-b.cursor.X)
+	line := b.lines[b.cursor.Y].cells
+	copy(line[b.cursor.X:end], line[b.cursor.X+p:end])
+	b.clearCells(b.cursor.Y, end-p, end)
+}
+
+// InsertCharacter inserts n empty characters at the cursor position
+// the characters from the cursor to the right margin are shifted right, the ones that overflow the margin are lost
+// ICH - Insert Character https://vt100.net/docs/vt510-rm/ICH.html
+func (b *Buffer) InsertCharacter(n int) {
+	if !b.cursorInLeftRightMargins() {
+		return
 	}
+	end := b.scrollAreaRight
+	p := clamp(n, 1, end-b.cursor.X)
+	line := b.lines[b.cursor.Y].cells
+	copy(line[b.cursor.X+p:end], line[b.cursor.X:end])
+	b.clearCells(b.cursor.Y, b.cursor.X, b.cursor.X+p)
 }
 
+// DeleteLine removes n lines from the cursor line down, the lines below (up to the bottom margin) move up
+// DL - Delete Line https://vt100.net/docs/vt510-rm/DL.html
 func (b *Buffer) DeleteLine(n int) {
-	m := clamp(n, 1, b.scrollAreaEnd-b.cursor.Y)
-	copy(b.lines[b.cursor.Y:], b.lines[b.cursor.Y+m:b.scrollAreaEnd])
-	for i := b.scrollAreaEnd - m; i < b.scrollAreaEnd; i++ {
-		b.lines[i] = b.newLine(b.size.Cols)
+	if !b.cursorInScrollArea() {
+		return
 	}
+	b.scrollRegionUp(b.cursor.Y, b.scrollAreaEnd, clamp(n, 1, b.scrollAreaEnd-b.cursor.Y))
 }
 
+// InsertLine inserts n empty lines at the cursor line, the lines below move down and the ones that overflow the bottom margin are lost
+// IL - Insert Line https://vt100.net/docs/vt510-rm/IL.html
 func (b *Buffer) InsertLine(n int) {
-	m := clamp(n, 1, b.scrollAreaEnd-b.cursor.Y)
-	copy(b.lines[b.cursor.Y+m:b.scrollAreaEnd], b.lines[b.cursor.Y:])
-	for i := b.cursor.Y; i < b.cursor.Y+m; i++ {
-		b.lines[i] = b.newLine(b.size.Cols)
+	if !b.cursorInScrollArea() {
+		return
 	}
+	b.scrollRegionDown(b.cursor.Y, b.scrollAreaEnd, clamp(n, 1, b.scrollAreaEnd-b.cursor.Y))
 }
 
 // ClearCurrentLine replaces the characters between start (inclusive) and end (exclusive) with spaces
@@ -232,23 +591,52 @@ func (b *Buffer) ClearCurrentLine(start, end int) {
 	s := clamp(start, 0, b.size.Cols)
 	e := clamp(end, s, b.size.Cols)
 
-	currentLineToClean := b.lines[b.cursor.Y][s:e]
+	currentLineToClean := b.lines[b.cursor.Y].cells[s:e]
+	b.damageLine(b.cursor.Y)
 	for i := range currentLineToClean {
 		currentLineToClean[i] = b.MakeRune(' ')
 	}
 }
 
 func (b *Buffer) Tab() {
-	newX := (b.cursor.X / 8 * 8) + 8
-	if newX < b.size.Cols {
-		b.cursor.X = newX
-	} else {
-		b.cursor.X = b.size.Cols - 1 // if the tab can't be fully added, lets move the cursor to the last column
+	end := b.rightEdge()
+	for x := b.cursor.X + 1; x < end; x++ {
+		if b.tabStops[x] {
+			b.cursor.X = x
+			return
+		}
 	}
+	b.cursor.X = end - 1 // if there is no tab stop after the cursor, lets move the cursor to the last column (or right margin)
+}
+
+// SetTabStop sets a tab stop at the cursor column
+// HTS - Horizontal Tab Set https://vt100.net/docs/vt510-rm/HTS.html
+func (b *Buffer) SetTabStop() {
+	b.tabStops[b.cursor.X] = true
+}
+
+// ClearTabStop removes the tab stop from the cursor column
+func (b *Buffer) ClearTabStop() {
+	b.tabStops[b.cursor.X] = false
 }
 
-func (b *Buffer) makeNewLines(size BufferSize) [][]BrushedRune {
-	newLines := make([][]BrushedRune, size.Rows)
+// ClearAllTabStops removes all tab stops, HT will then move the cursor to the last column
+func (b *Buffer) ClearAllTabStops() {
+	for i := range b.tabStops {
+		b.tabStops[i] = false
+	}
+}
+
+// resetTabStops sets a tab stop every 8 columns
+func (b *Buffer) resetTabStops() {
+	b.tabStops = make([]bool, b.size.Cols)
+	for i := 8; i < b.size.Cols; i += 8 {
+		b.tabStops[i] = true
+	}
+}
+
+func (b *Buffer) makeNewLines(size BufferSize) []line {
+	newLines := make([]line, size.Rows)
 	for r := range newLines {
 		newLines[r] = b.newLine(size.Cols)
 	}
@@ -265,7 +653,11 @@ func (b *Buffer) Resize(size BufferSize) bool {
 	b.size = size
 	b.lines = b.makeNewLines(size)
 	b.alternateLines = b.makeNewLines(size)
+	b.damage = newDamage(size.Rows)
 	b.resetScrollArea()
+	b.resetTabStops()
+	// make sure the cursor stays on the screen
+	b.SetCursor(b.cursor.X, b.cursor.Y)
 	fmt.Printf("buffer resized rows: %v, cols: %v\n", b.size.Rows, b.size.Cols)
 	return true
 }
@@ -279,14 +671,34 @@ func (b *Buffer) Backspace() {
 }
 
 func (b *Buffer) MoveCursorRelative(dx, dy int) {
+	x := b.cursor.X + dx
+	// the cursor can't move horizontally over the margins once it's inside them
+	if b.cursorInLeftRightMargins() {
+		x = clamp(x, b.scrollAreaLeft, b.scrollAreaRight-1)
+	}
 	b.SetCursor(
-		b.cursor.X+dx,
+		x,
 		clamp(b.cursor.Y+dy, b.scrollAreaStart, b.scrollAreaEnd),
 	)
 }
 
+// SaveCursor saves the cursor position, brush, origin mode, the wrapping flag and the charsets
+// DECSC - Save Cursor https://vt100.net/docs/vt510-rm/DECSC.html
 func (b *Buffer) SaveCursor() {
-	b.savedCursor = b.cursor
+	b.savedCursor = savedCursor{
+		cursor:         b.cursor,
+		brush:          b.brush,
+		originMode:     b.originMode,
+		nextWriteWraps: b.nextWriteWraps,
+		charsets:       b.charsets,
+		gl:             b.gl,
+	}
+}
+
+// defaultSavedCursor is the state that RestoreCursor restores if there was no SaveCursor
+// the cursor goes to the home position and the brush is reset
+func (b *Buffer) defaultSavedCursor() savedCursor {
+	return savedCursor{brush: Brush{}}
 }
 
 func (b *Buffer) SwitchToAlternateBuffer() {
@@ -296,11 +708,33 @@ func (b *Buffer) SwitchToAlternateBuffer() {
 	primaryLines := b.lines
 	b.lines = b.alternateLines
 	b.alternateLines = primaryLines
+	b.savedCursor, b.alternateSavedCursor = b.alternateSavedCursor, b.savedCursor
+	b.keyboardFlags, b.alternateKeyboardFlags = b.alternateKeyboardFlags, b.keyboardFlags
 	b.bufferType = bufAlternate
+	b.damageAll()
+	b.ResetView()
 	b.ClearLines(0, b.size.Rows)
 	b.SetCursor(0, 0)
 }
 
+// minX returns the index of the first column, this can be larger than 0 if the
+// left margin is set and the origin mode is enabled
+func (b *Buffer) minX() int {
+	if b.originMode {
+		return b.scrollAreaLeft
+	}
+	return 0
+}
+
+// maxX returns the index of the last column + 1, this can be smaller than b.size.Cols if the
+// right margin is set and the origin mode is enabled
+func (b *Buffer) maxX() int {
+	if b.originMode {
+		return b.scrollAreaRight
+	}
+	return b.size.Cols
+}
+
 // minY returns the index of the first row, this can be larger than 0 if the
 // scroll area is reduced and the origin mode is enabled
 func (b *Buffer) minY() int {
@@ -320,9 +754,10 @@ func (b *Buffer) maxY() int {
 }
 
 func (b *Buffer) SetCursor(x, y int) {
+	y = clamp(y, b.minY(), b.maxY()-1)
 	b.cursor = Cursor{
-		X: clamp(x, 0, b.size.Cols-1),
-		Y: clamp(y, b.minY(), b.maxY()-1),
+		X: clamp(x, b.minX(), min(b.maxX(), b.lineCols(y))-1),
+		Y: y,
 	}
 	b.nextWriteWraps = false
 }
@@ -334,38 +769,85 @@ func (b *Buffer) SwitchToPrimaryBuffer() {
 	alternateLines := b.lines
 	b.lines = b.alternateLines
 	b.alternateLines = alternateLines
+	b.savedCursor, b.alternateSavedCursor = b.alternateSavedCursor, b.savedCursor
+	b.keyboardFlags, b.alternateKeyboardFlags = b.alternateKeyboardFlags, b.keyboardFlags
 	b.bufferType = bufPrimary
+	b.damageAll()
 }
 
+// RestoreCursor restores the state saved by SaveCursor on the current screen
+// DECRC - Restore Cursor https://vt100.net/docs/vt510-rm/DECRC.html
 func (b *Buffer) RestoreCursor() {
-	b.cursor = b.savedCursor
+	sc := b.savedCursor
+	b.brush = sc.brush
+	b.originMode = sc.originMode
+	b.charsets = sc.charsets
+	b.gl = sc.gl
+	// the buffer could have been resized since we saved the cursor
+	b.cursor = Cursor{
+		X: clamp(sc.cursor.X, 0, b.size.Cols-1),
+		Y: clamp(sc.cursor.Y, 0, b.size.Rows-1),
+	}
+	b.nextWriteWraps = sc.nextWriteWraps && b.cursor == sc.cursor
 }
 
 // ReverseIndex Moves the active position to the same horizontal position on the preceding line. If the active position is at the top margin, a scroll down is performed. Format Effector
 // [docs}(https://vt100.net/docs/vt100-ug/chapter3.html)
 func (b *Buffer) ReverseIndex() {
 	if b.cursor.Y == b.scrollAreaStart {
-		b.scrollDown(1)
+		if b.cursorInLeftRightMargins() {
+			b.ScrollDown(1)
+		}
 	} else {
 		// TODO this can be probably written nicer
 		// I actually don't know what is the reverse index cursor up, should it
 		// be like relative cursor movement (clamped by scrolling area) or should
 		// U allow it to move outside of margins???
 		b.cursor.Y = clamp(b.cursor.Y-1, 0, b.cursor.Y)
+		b.cursor.X = min(b.cursor.X, b.lineCols(b.cursor.Y)-1)
 	}
 }
 
-func (b *Buffer) scrollDown(lines int) {
-	for i := b.scrollAreaEnd - lines - 1; i >= b.scrollAreaStart; i-- {
-		b.lines[i+lines] = b.lines[i]
-	}
-	for i := b.scrollAreaStart; i < b.scrollAreaStart+lines; i++ {
-		b.lines[i] = b.newLine(b.size.Cols)
-	}
+func (b *Buffer) OriginMode() bool {
+	return b.originMode
+}
+
+// AlternateScreen returns true if the alternate screen is active
+func (b *Buffer) AlternateScreen() bool {
+	return b.bufferType == bufAlternate
+}
+
+// SetSynchronizedOutput starts (true) or ends (false) a synchronized update, the screen shouldn't be rendered during the update
+// https://gist.github.com/christianparpart/d8a62cc1ab659194337d73e399004036
+func (b *Buffer) SetSynchronizedOutput(enabled bool) {
+	b.synchronizedOutput = enabled
+}
+
+func (b *Buffer) SynchronizedOutput() bool {
+	return b.synchronizedOutput
+}
+
+// SetFocusReporting enables or disables sending CSI I and CSI O when the window gains and loses focus
+func (b *Buffer) SetFocusReporting(enabled bool) {
+	b.focusReporting = enabled
+}
+
+func (b *Buffer) FocusReporting() bool {
+	return b.focusReporting
+}
+
+// SetInsertMode enables or disables the insert mode, written characters then shift the rest of the line right
+// IRM - Insert/Replace Mode https://vt100.net/docs/vt510-rm/IRM.html
+func (b *Buffer) SetInsertMode(enabled bool) {
+	b.insertMode = enabled
+}
+
+func (b *Buffer) InsertMode() bool {
+	return b.insertMode
 }
 
 func (b *Buffer) SetOriginMode(enabled bool) {
-	b.originMode = true
+	b.originMode = enabled
 	b.SetCursor(0, 0)
 }
 
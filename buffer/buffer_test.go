@@ -539,6 +539,385 @@ func TestInsertLine(t *testing.T) {
 	})
 }
 
+func TestTab(t *testing.T) {
+	t.Run("moves cursor to the next tab stop", func(t *testing.T) {
+		b := New(20, 1)
+		b.Tab()
+		if b.Cursor() != (Cursor{X: 8, Y: 0}) {
+			t.Fatalf("Cursor should be on the default tab stop (8,0), but is (%d,%d)", b.cursor.X, b.cursor.Y)
+		}
+	})
+
+	t.Run("uses custom tab stops", func(t *testing.T) {
+		b := New(20, 1)
+		b.ClearAllTabStops()
+		b.SetCursor(3, 0)
+		b.SetTabStop()
+		b.SetCursor(0, 0)
+		b.Tab()
+		if b.Cursor() != (Cursor{X: 3, Y: 0}) {
+			t.Fatalf("Cursor should be on the custom tab stop (3,0), but is (%d,%d)", b.cursor.X, b.cursor.Y)
+		}
+		b.Tab()
+		if b.Cursor() != (Cursor{X: 19, Y: 0}) {
+			t.Fatalf("Cursor should be on the last column (19,0), but is (%d,%d)", b.cursor.X, b.cursor.Y)
+		}
+	})
+
+	t.Run("clears tab stop under cursor", func(t *testing.T) {
+		b := New(20, 1)
+		b.SetCursor(8, 0)
+		b.ClearTabStop()
+		b.SetCursor(0, 0)
+		b.Tab()
+		if b.Cursor() != (Cursor{X: 16, Y: 0}) {
+			t.Fatalf("Cursor should skip the cleared tab stop and be on (16,0), but is (%d,%d)", b.cursor.X, b.cursor.Y)
+		}
+	})
+}
+
+func TestReset(t *testing.T) {
+	b := makeTestBuffer(t, `
+	ab
+	cd
+	`, 1, 1)
+	b.SetScrollArea(1, 2)
+	b.SetOriginMode(true)
+	b.SetBrush(Brush{Bold: true})
+	b.ClearAllTabStops()
+	b.SwitchToAlternateBuffer()
+	b.WriteRune('x')
+
+	b.Reset()
+
+	if b.String() != "  \n  \n" {
+		t.Fatalf("Reset should have cleared the screen, got:\n%q", b.String())
+	}
+	if b.bufferType != bufPrimary {
+		t.Fatal("Reset should have switched to the primary screen")
+	}
+	b.SwitchToPrimaryBuffer()
+	if b.String() != "  \n  \n" {
+		t.Fatalf("Reset should have cleared the primary screen, got:\n%q", b.String())
+	}
+	if b.Cursor() != (Cursor{}) {
+		t.Fatalf("Cursor should be in the home position, but is (%d,%d)", b.cursor.X, b.cursor.Y)
+	}
+	if b.originMode || b.scrollAreaStart != 0 || b.scrollAreaEnd != 2 {
+		t.Fatal("Reset should have reset the origin mode and the scroll area")
+	}
+	if b.Brush() != (Brush{}) {
+		t.Fatalf("Reset should have reset the brush, but it is %v", b.Brush())
+	}
+}
+
+func TestSoftReset(t *testing.T) {
+	b := makeTestBuffer(t, `
+	ab
+	cd
+	`, 1, 1)
+	b.SetScrollArea(1, 2)
+	b.SetOriginMode(true)
+	b.SetCursor(1, 0)
+	b.SetBrush(Brush{Bold: true})
+	b.SetCursorVisible(false)
+	b.SetInsertMode(true)
+	b.SetRectAttributeChangeExtent(true)
+
+	b.SoftReset()
+
+	expected := trimExpectation(t, `
+	ab
+	cd
+	`)
+	if b.String() != expected {
+		t.Fatalf("SoftReset should have kept the screen content\nExpected:\n%s\nGot:\n%s", expected, b.String())
+	}
+	if b.Cursor() != (Cursor{X: 1, Y: 1}) {
+		t.Fatalf("SoftReset should have kept the cursor position (1,1), but is (%d,%d)", b.cursor.X, b.cursor.Y)
+	}
+	if b.originMode || b.scrollAreaStart != 0 || b.scrollAreaEnd != 2 {
+		t.Fatal("SoftReset should have reset the origin mode and the scroll area")
+	}
+	if b.Brush() != (Brush{}) {
+		t.Fatalf("SoftReset should have reset the brush, but it is %v", b.Brush())
+	}
+	if !b.CursorVisible() {
+		t.Fatal("SoftReset should have made the cursor visible")
+	}
+	if b.InsertMode() || b.rectAttributeChange {
+		t.Fatal("SoftReset should have reset the insert mode (IRM) and the attribute change extent (DECSACE)")
+	}
+}
+
+func TestInsertMode(t *testing.T) {
+	b := makeTestBuffer(t, `
+	abcd
+	`, 1, 0)
+	b.SetInsertMode(true)
+	b.WriteRune('x')
+	if b.String() != "axbc\n" {
+		t.Fatalf("insert mode should shift the characters right, got:\n%s", b.String())
+	}
+	b.SetInsertMode(false)
+	b.WriteRune('y')
+	if b.String() != "axyc\n" {
+		t.Fatalf("replace mode should overwrite the character, got:\n%s", b.String())
+	}
+}
+
+func TestSaveCursor(t *testing.T) {
+	t.Run("restores position, brush, origin mode and wrapping", func(t *testing.T) {
+		b := New(3, 3)
+		br := Brush{FG: NewColor(1, 2, 3), Bold: true}
+		b.SetBrush(br)
+		b.SetCursor(0, 1)
+		b.WriteRune('a')
+		b.WriteRune('b')
+		b.WriteRune('c') // the next write wraps
+		b.SaveCursor()
+
+		b.ResetBrush()
+		b.SetOriginMode(true)
+		b.SetCursor(0, 0)
+		b.RestoreCursor()
+
+		if b.Cursor() != (Cursor{X: 2, Y: 1}) {
+			t.Fatalf("Cursor should be restored to (2,1), but is (%d,%d)", b.cursor.X, b.cursor.Y)
+		}
+		if b.Brush() != br {
+			t.Fatalf("Brush should be restored to %v, but is %v", br, b.Brush())
+		}
+		if b.originMode {
+			t.Fatal("Origin mode should be restored to false")
+		}
+		b.WriteRune('d')
+		if b.String() != "   \nabc\nd  \n" {
+			t.Fatalf("Restored cursor should wrap with the next write, got:\n%q", b.String())
+		}
+	})
+
+	t.Run("moves cursor home if there is no saved state", func(t *testing.T) {
+		b := New(3, 3)
+		b.SetCursor(2, 2)
+		b.SetBrush(Brush{Bold: true})
+		b.RestoreCursor()
+		if b.Cursor() != (Cursor{}) {
+			t.Fatalf("Cursor should be in home position, but is (%d,%d)", b.cursor.X, b.cursor.Y)
+		}
+		if b.Brush() != (Brush{}) {
+			t.Fatalf("Brush should be reset, but is %v", b.Brush())
+		}
+	})
+
+	t.Run("keeps separate state for primary and alternate screen", func(t *testing.T) {
+		b := New(3, 3)
+		b.SetCursor(1, 1)
+		b.SaveCursor()
+		b.SwitchToAlternateBuffer()
+		b.SetCursor(2, 2)
+		b.SaveCursor()
+		b.SwitchToPrimaryBuffer()
+		b.RestoreCursor()
+		if b.Cursor() != (Cursor{X: 1, Y: 1}) {
+			t.Fatalf("Primary screen cursor should be restored to (1,1), but is (%d,%d)", b.cursor.X, b.cursor.Y)
+		}
+		b.SwitchToAlternateBuffer()
+		b.RestoreCursor()
+		if b.Cursor() != (Cursor{X: 2, Y: 2}) {
+			t.Fatalf("Alternate screen cursor should be restored to (2,2), but is (%d,%d)", b.cursor.X, b.cursor.Y)
+		}
+	})
+}
+
+func TestLeftRightMargins(t *testing.T) {
+	t.Run("margins can't be set without left right margin mode", func(t *testing.T) {
+		b := New(4, 2)
+		b.SetLeftRightMargins(1, 3)
+		if !b.fullWidthScrollArea() {
+			t.Fatal("margins should not be set when the left right margin mode is disabled")
+		}
+	})
+
+	t.Run("scrolls only the columns within margins", func(t *testing.T) {
+		b := makeTestBuffer(t, `
+		abcd
+		efgh
+		ijkl
+		`, 0, 0)
+		expected := trimExpectation(t, `
+		afgd
+		ejkh
+		i__l
+		`)
+		b.SetLeftRightMarginMode(true)
+		b.SetLeftRightMargins(1, 3)
+		b.ScrollUp(1)
+		if b.String() != expected {
+			t.Fatalf("Buffer didn't scroll up within left and right margins\nExpected:\n%s\nGot:\n%s", expected, b.String())
+		}
+		b.ScrollDown(2)
+		expected = trimExpectation(t, `
+		a__d
+		e__h
+		ifgl
+		`)
+		if b.String() != expected {
+			t.Fatalf("Buffer didn't scroll down within left and right margins\nExpected:\n%s\nGot:\n%s", expected, b.String())
+		}
+	})
+
+	t.Run("wraps at the right margin", func(t *testing.T) {
+		b := New(4, 2)
+		b.SetLeftRightMarginMode(true)
+		b.SetLeftRightMargins(1, 3)
+		b.SetCursor(1, 0)
+		for _, r := range "abcd" {
+			b.WriteRune(r)
+		}
+		expected := trimExpectation(t, `
+		_ab_
+		_cd_
+		`)
+		if b.String() != expected {
+			t.Fatalf("Text didn't wrap within margins\nExpected:\n%s\nGot:\n%s", expected, b.String())
+		}
+		b.WriteRune('e')
+		expected = trimExpectation(t, `
+		_cd_
+		_e__
+		`)
+		if b.String() != expected {
+			t.Fatalf("Text didn't scroll within margins\nExpected:\n%s\nGot:\n%s", expected, b.String())
+		}
+	})
+
+	t.Run("inserts and deletes characters up to the right margin", func(t *testing.T) {
+		b := makeTestBuffer(t, `abcde`, 1, 0)
+		b.SetLeftRightMarginMode(true)
+		b.SetLeftRightMargins(0, 4)
+		b.SetCursor(1, 0)
+		b.InsertCharacter(1)
+		if b.String() != trimExpectation(t, "a_bce") {
+			t.Fatalf("InsertCharacter should have shifted characters up to the right margin, got:\n%q", b.String())
+		}
+		b.DeleteCharacter(2)
+		if b.String() != trimExpectation(t, "ac__e") {
+			t.Fatalf("DeleteCharacter should have shifted characters up to the right margin, got:\n%q", b.String())
+		}
+	})
+
+	t.Run("inserts and deletes lines within margins", func(t *testing.T) {
+		b := makeTestBuffer(t, `
+		abc
+		def
+		ghi
+		`, 0, 0)
+		b.SetLeftRightMarginMode(true)
+		b.SetLeftRightMargins(1, 3)
+		b.SetCursor(1, 0)
+		b.InsertLine(1)
+		expected := trimExpectation(t, `
+		a__
+		dbc
+		gef
+		`)
+		if b.String() != expected {
+			t.Fatalf("InsertLine should have only moved the columns within margins\nExpected:\n%s\nGot:\n%s", expected, b.String())
+		}
+		b.DeleteLine(2)
+		expected = trimExpectation(t, `
+		aef
+		d__
+		g__
+		`)
+		if b.String() != expected {
+			t.Fatalf("DeleteLine should have only moved the columns within margins\nExpected:\n%s\nGot:\n%s", expected, b.String())
+		}
+	})
+}
+
+func TestLineAttrs(t *testing.T) {
+	t.Run("double width line wraps in the middle of the screen", func(t *testing.T) {
+		b := New(4, 2)
+		b.SetLineAttr(LineDoubleWidth)
+		for _, r := range "abc" {
+			b.WriteRune(r)
+		}
+		expected := trimExpectation(t, `
+		ab__
+		c___
+		`)
+		if b.String() != expected {
+			t.Fatalf("double width line should contain only 2 characters\nExpected:\n%s\nGot:\n%s", expected, b.String())
+		}
+	})
+
+	t.Run("cursor can't move to the right half of double width line", func(t *testing.T) {
+		b := New(4, 2)
+		b.SetCursor(3, 0)
+		b.SetLineAttr(LineDoubleHeightTop)
+		if b.Cursor() != (Cursor{X: 1, Y: 0}) {
+			t.Fatalf("Cursor should move to the last column of the double width line (1,0), but is (%d,%d)", b.cursor.X, b.cursor.Y)
+		}
+		b.SetCursor(3, 0)
+		if b.Cursor() != (Cursor{X: 1, Y: 0}) {
+			t.Fatalf("Cursor should stay on the last column of the double width line (1,0), but is (%d,%d)", b.cursor.X, b.cursor.Y)
+		}
+	})
+
+	t.Run("makes the right half of the line empty", func(t *testing.T) {
+		b := makeTestBuffer(t, `abcd`, 0, 0)
+		b.SetLineAttr(LineDoubleWidth)
+		b.SetLineAttr(LineSingleWidth)
+		if b.String() != trimExpectation(t, "ab__") {
+			t.Fatalf("the right half of the line should have been erased, got:\n%q", b.String())
+		}
+	})
+
+	t.Run("line attributes scroll with the lines", func(t *testing.T) {
+		b := New(2, 2)
+		b.SetCursor(0, 1)
+		b.SetLineAttr(LineDoubleWidth)
+		b.ScrollUp(1)
+		attrs := b.LineAttrs()
+		if attrs[0] != LineDoubleWidth || attrs[1] != LineSingleWidth {
+			t.Fatalf("the double width line should have scrolled up, but line attributes are %v", attrs)
+		}
+	})
+
+	t.Run("clearing lines resets their attributes", func(t *testing.T) {
+		b := New(2, 2)
+		b.SetLineAttr(LineDoubleWidth)
+		b.ClearLines(0, 2)
+		if b.LineAttrs()[0] != LineSingleWidth {
+			t.Fatal("cleared line should be single width")
+		}
+	})
+}
+
+func TestScreenAlignment(t *testing.T) {
+	b := New(3, 3)
+	b.SetScrollArea(1, 2)
+	b.SetCursor(1, 1)
+	b.SetLineAttr(LineDoubleWidth)
+	b.ScreenAlignment()
+	expected := trimExpectation(t, `
+	EEE
+	EEE
+	EEE
+	`)
+	if b.String() != expected {
+		t.Fatalf("the screen should be filled with E\nExpected:\n%s\nGot:\n%s", expected, b.String())
+	}
+	if b.Cursor() != (Cursor{}) {
+		t.Fatalf("Cursor should be in the home position, but is (%d,%d)", b.cursor.X, b.cursor.Y)
+	}
+	if b.scrollAreaStart != 0 || b.scrollAreaEnd != 3 || b.LineAttrs()[1] != LineSingleWidth {
+		t.Fatal("DECALN should have reset margins and line attributes")
+	}
+}
+
 func makeTestBuffer(t testing.TB, content string, x, y int) *Buffer {
 	t.Helper()
 	rows := strings.Split(content, "\n")
@@ -585,3 +964,24 @@ func trimExpectation(t testing.TB, expected string) string {
 	// adds trailing new line because that's what the buffer.String() method does
 	return fmt.Sprintf("%s\n", strings.Join(trimmedRows, "\n"))
 }
+
+func TestClone(t *testing.T) {
+	b := makeTestBuffer(t, `
+	ab
+	cd
+	`, 0, 1)
+	b.LF()
+	clone := b.Clone()
+	b.WriteRune('x')
+	b.LF()
+	b.Mark(MarkPrompt)
+	if clone.String() != trimExpectation(t, `
+	cd
+	__
+	`) {
+		t.Fatalf("the clone should not change when the buffer changes, but is:\n%s", clone.String())
+	}
+	if clone.ScrollbackLen() != 1 || clone.LineMarks()[1] != 0 {
+		t.Fatalf("the clone scrollback and marks should not change when the buffer changes")
+	}
+}
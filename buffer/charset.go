@@ -0,0 +1,103 @@
+package buffer
+
+// Charset is a character set that can be designated into one of the G0-G3 slots.
+// The active charset translates the printed 7-bit characters into runes.
+// https://vt100.net/docs/vt220-rm/chapter2.html#S2.4
+type Charset int
+
+const (
+	CharsetASCII Charset = iota
+	// CharsetUK is the same as ASCII, except the pound sign replaces #
+	CharsetUK
+	// CharsetDECSpecialGraphics contains line drawing characters, used to draw boxes
+	CharsetDECSpecialGraphics
+)
+
+// decSpecialGraphics maps the characters 0x5f-0x7e to line drawing runes
+// https://vt100.net/docs/vt220-rm/table2-4.html
+var decSpecialGraphics = map[rune]rune{
+	'_': ' ',
+	'`': '◆',
+	'a': '▒',
+	'b': '␉',
+	'c': '␌',
+	'd': '␍',
+	'e': '␊',
+	'f': '°',
+	'g': '±',
+	'h': '␤',
+	'i': '␋',
+	'j': '┘',
+	'k': '┐',
+	'l': '┌',
+	'm': '└',
+	'n': '┼',
+	'o': '⎺',
+	'p': '⎻',
+	'q': '─',
+	'r': '⎼',
+	's': '⎽',
+	't': '├',
+	'u': '┤',
+	'v': '┴',
+	'w': '┬',
+	'x': '│',
+	'y': '≤',
+	'z': '≥',
+	'{': 'π',
+	'|': '≠',
+	'}': '£',
+	'~': '·',
+}
+
+func (cs Charset) translate(r rune) rune {
+	switch cs {
+	case CharsetUK:
+		if r == '#' {
+			return '£'
+		}
+	case CharsetDECSpecialGraphics:
+		if tr, ok := decSpecialGraphics[r]; ok {
+			return tr
+		}
+	}
+	return r
+}
+
+// DesignateCharset puts the charset into one of the G0-G3 slots (g is 0-3)
+// SCS - Select Character Set https://vt100.net/docs/vt510-rm/SCS.html
+func (b *Buffer) DesignateCharset(g int, cs Charset) {
+	if g < 0 || g > 3 {
+		return
+	}
+	b.charsets[g] = cs
+}
+
+// LockingShift invokes the G0-G3 charset into GL, the charset stays active until the next locking shift
+// SO (G1) and SI (G0) https://vt100.net/docs/vt510-rm/LS0.html
+func (b *Buffer) LockingShift(g int) {
+	if g < 0 || g > 3 {
+		return
+	}
+	b.gl = g
+}
+
+// SingleShift invokes G2 or G3 charset only for the next printed character
+// SS2 and SS3 https://vt100.net/docs/vt510-rm/SS2.html
+func (b *Buffer) SingleShift(g int) {
+	if g != 2 && g != 3 {
+		return
+	}
+	b.singleShift = g
+}
+
+// TranslateRune translates the printed rune with the active charset
+// it should be called once for every printed rune before WriteRune, because it consumes the single shift
+func (b *Buffer) TranslateRune(r rune) rune {
+	cs := b.charsets[b.gl]
+	if b.singleShift != 0 {
+		cs = b.charsets[b.singleShift]
+		b.singleShift = 0
+	}
+	return cs.translate(r)
+}
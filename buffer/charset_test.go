@@ -0,0 +1,51 @@
+package buffer
+
+import "testing"
+
+func TestCharsets(t *testing.T) {
+	t.Run("translates line drawing characters", func(t *testing.T) {
+		b := New(5, 1)
+		b.DesignateCharset(0, CharsetDECSpecialGraphics)
+		for _, r := range "lqqka" {
+			b.WriteRune(b.TranslateRune(r))
+		}
+		if b.String() != "┌──┐▒\n" {
+			t.Fatalf("DEC special graphics weren't translated, got:\n%s", b.String())
+		}
+	})
+
+	t.Run("locking shift switches between G0 and G1", func(t *testing.T) {
+		b := New(4, 1)
+		b.DesignateCharset(1, CharsetUK)
+		b.WriteRune(b.TranslateRune('#'))
+		b.LockingShift(1)
+		b.WriteRune(b.TranslateRune('#'))
+		b.LockingShift(0)
+		b.WriteRune(b.TranslateRune('#'))
+		if b.String() != "#£# \n" {
+			t.Fatalf("locking shift didn't switch charsets, got:\n%s", b.String())
+		}
+	})
+
+	t.Run("single shift applies only to the next character", func(t *testing.T) {
+		b := New(3, 1)
+		b.DesignateCharset(2, CharsetDECSpecialGraphics)
+		b.SingleShift(2)
+		b.WriteRune(b.TranslateRune('x'))
+		b.WriteRune(b.TranslateRune('x'))
+		if b.String() != "│x \n" {
+			t.Fatalf("single shift should have translated only one character, got:\n%s", b.String())
+		}
+	})
+
+	t.Run("charsets are saved with the cursor", func(t *testing.T) {
+		b := New(3, 1)
+		b.DesignateCharset(0, CharsetDECSpecialGraphics)
+		b.SaveCursor()
+		b.DesignateCharset(0, CharsetASCII)
+		b.RestoreCursor()
+		if r := b.TranslateRune('q'); r != '─' {
+			t.Fatalf("restored charset should translate q to ─, but got %q", r)
+		}
+	})
+}
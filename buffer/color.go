@@ -2,24 +2,153 @@ package buffer
 
 import "fmt"
 
-// FIXME: Ideally, the buffer would represent the colors the same way that the
-// SGR instruction does and the GUI layer would then translate them
-// that would allow for easy changes to the color theme
-var (
-	DefaultFG = NewColor(0xeb, 0xdb, 0xb2)
-	DefaultBG = NewColor(0x28, 0x28, 0x28)
+// ColorType says how to translate the Color to RGB
+type ColorType uint8
+
+const (
+	// ColorDefault is the default foreground or background color from the palette
+	ColorDefault ColorType = iota
+	// ColorIndexed is one of the 256 colors from the palette
+	ColorIndexed
+	// ColorRGB is a true color that doesn't depend on the palette
+	ColorRGB
 )
 
+// Color is represented the same way that the SGR instruction sets it,
+// the GUI translates it to RGB with the Palette so changing the palette changes the color of existing characters
 type Color struct {
-	R uint8
-	G uint8
-	B uint8
+	Type ColorType
+	// Index is the palette index of the ColorIndexed color
+	Index uint8
+	// R, G and B are the components of the ColorRGB color
+	R, G, B uint8
 }
 
+// DefaultColor is the default foreground or background color
+var DefaultColor = Color{}
+
+// NewColor returns a true color
 func NewColor(r, g, b uint8) Color {
-	return Color{R: r, G: g, B: b}
+	return Color{Type: ColorRGB, R: r, G: g, B: b}
+}
+
+// IndexedColor returns a palette color, 0-7 are the normal colors, 8-15 the bright colors
+// 16-231 is the 6x6x6 color cube and 232-255 is the grayscale
+func IndexedColor(i uint8) Color {
+	return Color{Type: ColorIndexed, Index: i}
 }
 
 func (c Color) String() string {
-	return fmt.Sprintf("#%x%x%x", c.R, c.G, c.B)
+	switch c.Type {
+	case ColorIndexed:
+		return fmt.Sprintf("color%d", c.Index)
+	case ColorRGB:
+		return RGB{R: c.R, G: c.G, B: c.B}.String()
+	default:
+		return "default"
+	}
+}
+
+// RGB is the color that the GUI paints
+type RGB struct {
+	R, G, B uint8
+}
+
+func (c RGB) String() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Palette translates colors to RGB, programs can change it with OSC 4, 10, 11 and 12
+type Palette struct {
+	Indexed [256]RGB
+	FG      RGB
+	BG      RGB
+	Cursor  RGB
+}
+
+// DefaultPalette returns the palette that gritty uses if the user doesn't configure a different one
+// the 16 basic colors are from the VS Code color palette
+// more info here https://en.wikipedia.org/wiki/ANSI_escape_code#3-bit_and_4-bit
+func DefaultPalette() Palette {
+	p := Palette{
+		FG:     RGB{0xeb, 0xdb, 0xb2},
+		BG:     RGB{0x28, 0x28, 0x28},
+		Cursor: RGB{0xeb, 0xdb, 0xb2},
+	}
+	basic := []RGB{
+		{0, 0, 0},       // black
+		{205, 49, 49},   // red
+		{13, 188, 121},  // green
+		{229, 229, 16},  // yellow
+		{36, 114, 200},  // blue
+		{188, 63, 188},  // magenta
+		{17, 168, 205},  // cyan
+		{229, 229, 229}, // white
+		{102, 102, 102}, // bright black
+		{241, 76, 76},   // bright red
+		{35, 209, 139},  // bright green
+		{245, 245, 67},  // bright yellow
+		{59, 142, 234},  // bright blue
+		{214, 112, 214}, // bright magenta
+		{41, 184, 219},  // bright cyan
+		{229, 229, 229}, // bright white
+	}
+	copy(p.Indexed[:], basic)
+	// 6x6x6 color cube
+	levels := []uint8{0, 95, 135, 175, 215, 255}
+	for i := 0; i < 216; i++ {
+		p.Indexed[16+i] = RGB{levels[(i/36)%6], levels[(i/6)%6], levels[i%6]}
+	}
+	// grayscale
+	for i := 0; i < 24; i++ {
+		level := uint8(8 + i*10)
+		p.Indexed[232+i] = RGB{level, level, level}
+	}
+	return p
+}
+
+// FGColor translates the foreground color to RGB
+func (p *Palette) FGColor(c Color) RGB {
+	if c.Type == ColorDefault {
+		return p.FG
+	}
+	return p.color(c)
+}
+
+// BGColor translates the background color to RGB
+func (p *Palette) BGColor(c Color) RGB {
+	if c.Type == ColorDefault {
+		return p.BG
+	}
+	return p.color(c)
+}
+
+func (p *Palette) color(c Color) RGB {
+	if c.Type == ColorIndexed {
+		return p.Indexed[c.Index]
+	}
+	return RGB{R: c.R, G: c.G, B: c.B}
+}
+
+// Palette returns the current palette
+func (b *Buffer) Palette() Palette {
+	return b.palette
+}
+
+// SetPalette replaces the current palette, the theme stays the same so the colors can be reset back to it
+func (b *Buffer) SetPalette(p Palette) {
+	b.palette = p
+	b.damageAll()
+}
+
+// Theme returns the palette that the colors reset to
+func (b *Buffer) Theme() Palette {
+	return b.theme
+}
+
+// SetTheme changes the palette that the colors reset to and resets the current palette to it
+func (b *Buffer) SetTheme(p Palette) {
+	b.theme = p
+	b.palette = p
+	b.damageAll()
 }
@@ -0,0 +1,43 @@
+package buffer
+
+import "testing"
+
+func TestPalette(t *testing.T) {
+	t.Run("translates colors to RGB", func(t *testing.T) {
+		p := DefaultPalette()
+		p.Indexed[1] = RGB{R: 1}
+		testCases := []struct {
+			desc   string
+			color  Color
+			fg, bg RGB
+		}{
+			{desc: "default", color: DefaultColor, fg: p.FG, bg: p.BG},
+			{desc: "indexed", color: IndexedColor(1), fg: RGB{R: 1}, bg: RGB{R: 1}},
+			{desc: "true color", color: NewColor(1, 2, 3), fg: RGB{R: 1, G: 2, B: 3}, bg: RGB{R: 1, G: 2, B: 3}},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.desc, func(t *testing.T) {
+				if fg := p.FGColor(tc.color); fg != tc.fg {
+					t.Fatalf("foreground should have been %v, but was %v", tc.fg, fg)
+				}
+				if bg := p.BGColor(tc.color); bg != tc.bg {
+					t.Fatalf("background should have been %v, but was %v", tc.bg, bg)
+				}
+			})
+		}
+	})
+
+	t.Run("reset restores the theme", func(t *testing.T) {
+		b := New(2, 2)
+		theme := DefaultPalette()
+		theme.BG = RGB{R: 1}
+		b.SetTheme(theme)
+		p := b.Palette()
+		p.BG = RGB{R: 2}
+		b.SetPalette(p)
+		b.Reset()
+		if b.Palette() != theme || b.Theme() != theme {
+			t.Fatalf("the palette should have been reset to the theme")
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package buffer
+
+// CursorShape is the shape the GUI uses to draw the cursor
+type CursorShape int
+
+const (
+	CursorBlock CursorShape = iota
+	CursorUnderline
+	CursorBar
+)
+
+// CursorStyle describes how the cursor looks, programs can change it with DECSCUSR
+type CursorStyle struct {
+	Shape    CursorShape
+	Blinking bool
+}
+
+// DefaultCursorStyle is the blinking block, it's the style that DECSCUSR 0 sets unless the user configures a different one
+var DefaultCursorStyle = CursorStyle{Shape: CursorBlock, Blinking: true}
+
+func (b *Buffer) CursorStyle() CursorStyle {
+	return b.cursorStyle
+}
+
+// SetCursorStyle changes the shape and blinking of the cursor
+// DECSCUSR - Set Cursor Style https://vt100.net/docs/vt510-rm/DECSCUSR.html
+func (b *Buffer) SetCursorStyle(style CursorStyle) {
+	b.cursorStyle = style
+}
+
+// SetDefaultCursorStyle changes the style that ResetCursorStyle sets and resets the cursor to it
+func (b *Buffer) SetDefaultCursorStyle(style CursorStyle) {
+	b.defaultCursorStyle = style
+	b.cursorStyle = style
+}
+
+// ResetCursorStyle sets the cursor style to the default style (DECSCUSR 0)
+func (b *Buffer) ResetCursorStyle() {
+	b.cursorStyle = b.defaultCursorStyle
+}
+
+func (b *Buffer) CursorVisible() bool {
+	return b.cursorVisible
+}
+
+// SetCursorVisible shows or hides the cursor
+// DECTCEM - Text Cursor Enable Mode https://vt100.net/docs/vt510-rm/DECTCEM.html
+func (b *Buffer) SetCursorVisible(visible bool) {
+	b.cursorVisible = visible
+}
@@ -0,0 +1,85 @@
+package buffer
+
+// damage remembers which lines changed since the consumer (e.g. the GUI) last took the damage with TakeDamage
+type damage struct {
+	// lines are the changed rows on the screen
+	lines []bool
+	// all means that the whole view changed (e.g. after resize, scrolling the view or changing the palette)
+	all bool
+	// cursor and cursorVisible is the cursor state from the last TakeDamage, the cursor lines are damaged when it changes
+	cursor        Cursor
+	cursorVisible bool
+}
+
+func newDamage(rows int) damage {
+	return damage{lines: make([]bool, rows), all: true}
+}
+
+// damageLine marks the screen row as changed
+func (b *Buffer) damageLine(row int) {
+	if row >= 0 && row < len(b.damage.lines) {
+		b.damage.lines[row] = true
+	}
+}
+
+// damageLines marks the screen rows between start (inclusive) and end (exclusive) as changed
+func (b *Buffer) damageLines(start, end int) {
+	for row := start; row < end; row++ {
+		b.damageLine(row)
+	}
+}
+
+// damageHistoryLine marks the line with the history index (see historyLine) as changed
+func (b *Buffer) damageHistoryLine(i int) {
+	if i >= len(b.scrollback) {
+		b.damageLine(i - len(b.scrollback))
+	} else if i >= b.viewTop() {
+		// scrollback lines don't have a screen row, but the line is in the view
+		b.damageAll()
+	}
+}
+
+// damageAll marks the whole view as changed
+func (b *Buffer) damageAll() {
+	b.damage.all = true
+}
+
+// TakeDamage returns the rows of the view that changed since the last call, in ascending order.
+// A row changes when its characters, attributes or marks change or when the cursor moves into or out of it.
+// The damage is cleared, so the consumer is expected to redraw the returned rows.
+func (b *Buffer) TakeDamage() []int {
+	changed := make([]bool, b.size.Rows)
+	if b.damage.all {
+		for row := range changed {
+			changed[row] = true
+		}
+	}
+	// the screen rows move down in the view when it's scrolled up into the scrollback
+	for row, damaged := range b.damage.lines {
+		if damaged && row+b.viewOffset < b.size.Rows {
+			changed[row+b.viewOffset] = true
+		}
+	}
+	cursor, visible := b.ViewCursor()
+	visible = visible && b.cursorVisible
+	if cursor != b.damage.cursor || visible != b.damage.cursorVisible {
+		for _, c := range []struct {
+			cursor  Cursor
+			visible bool
+		}{{b.damage.cursor, b.damage.cursorVisible}, {cursor, visible}} {
+			if c.visible && c.cursor.Y < b.size.Rows {
+				changed[c.cursor.Y] = true
+			}
+		}
+	}
+	var rows []int
+	for row, damaged := range changed {
+		if damaged {
+			rows = append(rows, row)
+		}
+	}
+	clear(b.damage.lines)
+	b.damage.all = false
+	b.damage.cursor, b.damage.cursorVisible = cursor, visible
+	return rows
+}
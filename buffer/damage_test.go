@@ -0,0 +1,99 @@
+package buffer
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDamage(t *testing.T) {
+	// makeCleanBuffer returns a 4x4 buffer with the cursor at 1,1 and no damage
+	makeCleanBuffer := func(t *testing.T) *Buffer {
+		b := makeTestBuffer(t, `
+		abcd
+		efgh
+		ijkl
+		mnop
+		`, 1, 1)
+		b.TakeDamage()
+		return b
+	}
+
+	testCases := []struct {
+		desc     string
+		change   func(b *Buffer)
+		expected []int
+	}{
+		{desc: "new buffer is damaged whole", change: func(b *Buffer) { *b = *New(4, 4) }, expected: []int{0, 1, 2, 3}},
+		{desc: "no change", change: func(b *Buffer) {}, expected: nil},
+		{desc: "WriteRune", change: func(b *Buffer) { b.WriteRune('x') }, expected: []int{1}},
+		{desc: "cursor move", change: func(b *Buffer) { b.SetCursor(0, 3) }, expected: []int{1, 3}},
+		{desc: "hidden cursor", change: func(b *Buffer) { b.SetCursorVisible(false) }, expected: []int{1}},
+		{desc: "ClearLines", change: func(b *Buffer) { b.ClearLines(2, 4) }, expected: []int{2, 3}},
+		{desc: "ClearCurrentLine", change: func(b *Buffer) { b.ClearCurrentLine(0, 2) }, expected: []int{1}},
+		{desc: "DeleteCharacter", change: func(b *Buffer) { b.DeleteCharacter(1) }, expected: []int{1}},
+		{desc: "InsertCharacter", change: func(b *Buffer) { b.InsertCharacter(1) }, expected: []int{1}},
+		{desc: "InsertLine", change: func(b *Buffer) { b.InsertLine(1) }, expected: []int{1, 2, 3}},
+		{desc: "DeleteLine", change: func(b *Buffer) { b.DeleteLine(1) }, expected: []int{1, 2, 3}},
+		{desc: "ScrollUp in the scroll area", change: func(b *Buffer) {
+			b.SetScrollArea(0, 2)
+			b.ScrollUp(1)
+			b.SetCursor(1, 1)
+		}, expected: []int{0, 1}},
+		{desc: "ScrollDown", change: func(b *Buffer) { b.ScrollDown(1) }, expected: []int{0, 1, 2, 3}},
+		{desc: "SetLineAttr", change: func(b *Buffer) { b.SetLineAttr(LineDoubleWidth) }, expected: []int{1}},
+		{desc: "FillRect", change: func(b *Buffer) { b.FillRect(Rect{Top: 2, Left: 0, Bottom: 3, Right: 2}, 'x') }, expected: []int{2}},
+		{desc: "CopyRect", change: func(b *Buffer) {
+			b.CopyRect(Rect{Top: 0, Left: 0, Bottom: 1, Right: 2}, Rect{Top: 3, Left: 0, Bottom: 4, Right: 4})
+		}, expected: []int{3}},
+		{desc: "ChangeRectAttributes", change: func(b *Buffer) {
+			b.ChangeRectAttributes(Rect{Top: 0, Left: 0, Bottom: 2, Right: 2}, func(br Brush) Brush { br.Bold = true; return br })
+		}, expected: []int{0, 1}},
+		{desc: "Mark", change: func(b *Buffer) { b.Mark(MarkPrompt) }, expected: []int{1}},
+		{desc: "SetPalette", change: func(b *Buffer) { b.SetPalette(Palette{}) }, expected: []int{0, 1, 2, 3}},
+		{desc: "alternate screen", change: func(b *Buffer) {
+			b.SwitchToAlternateBuffer()
+			b.SetCursor(1, 1)
+		}, expected: []int{0, 1, 2, 3}},
+		{desc: "Resize", change: func(b *Buffer) {
+			b.Resize(BufferSize{Rows: 2, Cols: 2})
+			b.SetCursor(1, 1)
+		}, expected: []int{0, 1}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			b := makeCleanBuffer(t)
+			tc.change(b)
+			if damage := b.TakeDamage(); !slices.Equal(damage, tc.expected) {
+				t.Fatalf("expected damaged rows %v, but got %v", tc.expected, damage)
+			}
+			if damage := b.TakeDamage(); damage != nil {
+				t.Fatalf("TakeDamage should clear the damage, but the second call returned %v", damage)
+			}
+		})
+	}
+
+	t.Run("scrolling the view damages the whole view", func(t *testing.T) {
+		b := makeCleanBuffer(t)
+		b.SetCursor(0, 3)
+		b.LF()
+		b.TakeDamage()
+		b.ScrollView(1)
+		if damage := b.TakeDamage(); !slices.Equal(damage, []int{0, 1, 2, 3}) {
+			t.Fatalf("expected the whole view to be damaged, but got %v", damage)
+		}
+	})
+
+	t.Run("screen changes move down in the scrolled view", func(t *testing.T) {
+		b := makeCleanBuffer(t)
+		b.SetCursor(0, 3)
+		b.LF()
+		b.ScrollView(1)
+		b.TakeDamage()
+		b.SetCursor(0, 0)
+		b.TakeDamage()
+		b.WriteRune('x')
+		if damage := b.TakeDamage(); !slices.Equal(damage, []int{1}) {
+			t.Fatalf("expected the first screen line to be damaged on the second view row, but got %v", damage)
+		}
+	})
+}
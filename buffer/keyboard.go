@@ -0,0 +1,52 @@
+package buffer
+
+// KeyboardFlags are the progressive enhancements of the kitty keyboard protocol, they change how the keys are encoded
+// https://sw.kovidgoyal.net/kitty/keyboard-protocol/#progressive-enhancement
+type KeyboardFlags uint8
+
+const (
+	// KeyboardDisambiguate encodes Esc and the keys with modifiers as CSI u so they can't be mistaken for other keys
+	KeyboardDisambiguate KeyboardFlags = 1 << iota
+	// KeyboardEventTypes reports the key releases
+	KeyboardEventTypes
+	// KeyboardAlternateKeys reports the shifted key together with the key
+	KeyboardAlternateKeys
+	// KeyboardAllKeys encodes all keys as escape codes, even the keys that produce text
+	KeyboardAllKeys
+	// KeyboardAssociatedText reports the text that the key produces
+	KeyboardAssociatedText
+)
+
+// maxKeyboardFlagsStack limits the stack, so programs that push without popping can't use unlimited memory
+const maxKeyboardFlagsStack = 16
+
+// KeyboardFlags returns the flags on the top of the current screen stack, 0 means the legacy encoding
+func (b *Buffer) KeyboardFlags() KeyboardFlags {
+	if len(b.keyboardFlags) == 0 {
+		return 0
+	}
+	return b.keyboardFlags[len(b.keyboardFlags)-1]
+}
+
+// PushKeyboardFlags makes the flags current, PopKeyboardFlags returns to the previous flags.
+// The oldest flags are dropped when the stack is full.
+func (b *Buffer) PushKeyboardFlags(flags KeyboardFlags) {
+	if len(b.keyboardFlags) == maxKeyboardFlagsStack {
+		b.keyboardFlags = b.keyboardFlags[1:]
+	}
+	b.keyboardFlags = append(b.keyboardFlags, flags)
+}
+
+// PopKeyboardFlags removes n flags from the stack, popping more flags than the stack contains resets the flags
+func (b *Buffer) PopKeyboardFlags(n int) {
+	b.keyboardFlags = b.keyboardFlags[:max(0, len(b.keyboardFlags)-n)]
+}
+
+// SetKeyboardFlags replaces the flags on the top of the stack
+func (b *Buffer) SetKeyboardFlags(flags KeyboardFlags) {
+	if len(b.keyboardFlags) == 0 {
+		b.keyboardFlags = append(b.keyboardFlags, flags)
+		return
+	}
+	b.keyboardFlags[len(b.keyboardFlags)-1] = flags
+}
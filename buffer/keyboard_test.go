@@ -0,0 +1,51 @@
+package buffer
+
+import "testing"
+
+func TestKeyboardFlags(t *testing.T) {
+	t.Run("push, set and pop", func(t *testing.T) {
+		b := New(5, 5)
+		b.PushKeyboardFlags(KeyboardDisambiguate)
+		b.PushKeyboardFlags(KeyboardAllKeys)
+		b.SetKeyboardFlags(KeyboardAllKeys | KeyboardEventTypes)
+		if b.KeyboardFlags() != KeyboardAllKeys|KeyboardEventTypes {
+			t.Fatalf("set should replace the top flags, but the flags are %d", b.KeyboardFlags())
+		}
+		b.PopKeyboardFlags(1)
+		if b.KeyboardFlags() != KeyboardDisambiguate {
+			t.Fatalf("pop should return the previous flags, but the flags are %d", b.KeyboardFlags())
+		}
+		b.PopKeyboardFlags(5)
+		if b.KeyboardFlags() != 0 {
+			t.Fatalf("popping everything should reset the flags, but the flags are %d", b.KeyboardFlags())
+		}
+	})
+
+	t.Run("the stack is limited", func(t *testing.T) {
+		b := New(5, 5)
+		for i := 0; i < maxKeyboardFlagsStack*2; i++ {
+			b.PushKeyboardFlags(KeyboardFlags(i))
+		}
+		if len(b.keyboardFlags) != maxKeyboardFlagsStack || b.KeyboardFlags() != KeyboardFlags(maxKeyboardFlagsStack*2-1) {
+			t.Fatalf("the stack should keep the newest %d flags, but it is %v", maxKeyboardFlagsStack, b.keyboardFlags)
+		}
+	})
+
+	t.Run("every screen has its own stack", func(t *testing.T) {
+		b := New(5, 5)
+		b.PushKeyboardFlags(KeyboardDisambiguate)
+		b.SwitchToAlternateBuffer()
+		if b.KeyboardFlags() != 0 {
+			t.Fatalf("the alternate screen should start with the legacy encoding, but the flags are %d", b.KeyboardFlags())
+		}
+		b.PushKeyboardFlags(KeyboardAllKeys)
+		b.SwitchToPrimaryBuffer()
+		if b.KeyboardFlags() != KeyboardDisambiguate {
+			t.Fatalf("the primary screen should keep its flags, but the flags are %d", b.KeyboardFlags())
+		}
+		b.Reset()
+		if b.KeyboardFlags() != 0 {
+			t.Fatalf("reset should clear the flags, but the flags are %d", b.KeyboardFlags())
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package buffer
+
+import (
+	"net/url"
+)
+
+// maxLinkURILength is the longest URI that we store, longer URIs are ignored.
+// The link limits only keep the link table small, the parser already limits how much of the OSC it keeps in memory.
+const maxLinkURILength = 2048
+
+// maxLinkIDLength is the longest link ID that we store, longer IDs are ignored
+const maxLinkIDLength = 256
+
+// maxLinks is the number of links the link table can hold before we remove links that are no longer on the screen or in the scrollback
+const maxLinks = 4096
+
+// Link is a hyperlink attached to characters with OSC 8
+// https://gist.github.com/egmontkob/eb114294efbcd5adb1944c9f3cb5feda
+type Link struct {
+	// ID connects characters into one link even if they are not next to each other (e.g. link wrapped by a text editor), it's optional
+	ID  string
+	URI string
+}
+
+// linkTable stores links referenced from BrushedRune.Link, the 0 reference means no link
+type linkTable struct {
+	links map[int]Link
+	refs  map[Link]int
+	next  int
+}
+
+func newLinkTable() linkTable {
+	return linkTable{links: map[int]Link{}, refs: map[Link]int{}, next: 1}
+}
+
+// ref returns the reference for the link, adding the link into the table if it's not there yet
+func (t *linkTable) ref(l Link) int {
+	if ref, ok := t.refs[l]; ok {
+		return ref
+	}
+	ref := t.next
+	t.next++
+	t.links[ref] = l
+	t.refs[l] = ref
+	return ref
+}
+
+// validLink returns true if the link is small enough and the URI contains a scheme
+func validLink(l Link) bool {
+	if len(l.URI) > maxLinkURILength || len(l.ID) > maxLinkIDLength {
+		return false
+	}
+	for _, c := range l.URI {
+		if c <= ' ' || c >= 0x7f {
+			return false
+		}
+	}
+	u, err := url.Parse(l.URI)
+	return err == nil && u.Scheme != ""
+}
+
+// StartLink makes all following written characters part of the link, the link ends with EndLink.
+// Invalid links (too long or without a scheme) end the current link instead.
+// OSC 8 - Hyperlink https://gist.github.com/egmontkob/eb114294efbcd5adb1944c9f3cb5feda
+func (b *Buffer) StartLink(id, uri string) {
+	l := Link{ID: id, URI: uri}
+	if !validLink(l) {
+		b.EndLink()
+		return
+	}
+	if _, ok := b.links.refs[l]; !ok && len(b.links.links) >= maxLinks {
+		b.pruneLinks()
+		if len(b.links.links) >= maxLinks {
+			b.EndLink()
+			return
+		}
+	}
+	b.currentLink = b.links.ref(l)
+}
+
+// EndLink stops adding the link to the written characters
+func (b *Buffer) EndLink() {
+	b.currentLink = 0
+}
+
+// Link returns the link that the BrushedRune.Link references
+func (b *Buffer) Link(ref int) (Link, bool) {
+	l, ok := b.links.links[ref]
+	return l, ok
+}
+
+// pruneLinks removes links that aren't referenced by any character on the screens or in the scrollback
+func (b *Buffer) pruneLinks() {
+	used := map[int]bool{b.currentLink: true}
+	for _, lines := range [][]line{b.lines, b.alternateLines, b.scrollback} {
+		for _, l := range lines {
+			for _, c := range l.cells {
+				used[c.Link] = true
+			}
+		}
+	}
+	for ref, l := range b.links.links {
+		if !used[ref] {
+			delete(b.links.links, ref)
+			delete(b.links.refs, l)
+		}
+	}
+}
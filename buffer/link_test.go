@@ -0,0 +1,107 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLinks(t *testing.T) {
+	t.Run("written characters reference the link", func(t *testing.T) {
+		b := New(4, 1)
+		b.WriteRune('a')
+		b.StartLink("", "https://example.com")
+		b.WriteRune('b')
+		b.WriteRune('c')
+		b.EndLink()
+		b.WriteRune('d')
+		runes := b.Runes()
+		if runes[0].Link != 0 || runes[3].Link != 0 {
+			t.Fatalf("characters outside of the link shouldn't reference it")
+		}
+		if runes[1].Link == 0 || runes[1].Link != runes[2].Link {
+			t.Fatalf("characters inside the link should reference the same link, got %d and %d", runes[1].Link, runes[2].Link)
+		}
+		l, ok := b.Link(runes[1].Link)
+		if !ok || l.URI != "https://example.com" {
+			t.Fatalf("the link should have been https://example.com, but was %v", l)
+		}
+	})
+
+	t.Run("links with the same ID and URI share the reference", func(t *testing.T) {
+		b := New(3, 1)
+		b.StartLink("1", "https://example.com")
+		b.WriteRune('a')
+		b.StartLink("2", "https://example.com")
+		b.WriteRune('b')
+		b.StartLink("1", "https://example.com")
+		b.WriteRune('c')
+		runes := b.Runes()
+		if runes[0].Link != runes[2].Link || runes[0].Link == runes[1].Link {
+			t.Fatalf("only links with the same ID should share the reference, got %d, %d, %d", runes[0].Link, runes[1].Link, runes[2].Link)
+		}
+	})
+
+	t.Run("erased characters don't reference the link", func(t *testing.T) {
+		b := New(3, 1)
+		b.StartLink("", "https://example.com")
+		b.WriteRune('a')
+		b.ClearCurrentLine(0, 3)
+		if b.Runes()[0].Link != 0 {
+			t.Fatalf("erased character should not reference the link")
+		}
+	})
+
+	t.Run("ignores invalid links", func(t *testing.T) {
+		testCases := []struct {
+			desc string
+			id   string
+			uri  string
+		}{
+			{desc: "URI without scheme", uri: "example.com"},
+			{desc: "URI with control characters", uri: "https://example.com/\x01"},
+			{desc: "URI that is too long", uri: "https://example.com/" + strings.Repeat("a", maxLinkURILength)},
+			{desc: "ID that is too long", id: strings.Repeat("a", maxLinkIDLength+1), uri: "https://example.com"},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.desc, func(t *testing.T) {
+				b := New(2, 1)
+				b.StartLink("", "https://example.com")
+				b.StartLink(tc.id, tc.uri)
+				b.WriteRune('a')
+				if b.Runes()[0].Link != 0 {
+					t.Fatalf("invalid link should end the current link")
+				}
+			})
+		}
+	})
+
+	t.Run("removes links that are no longer used when the table is full", func(t *testing.T) {
+		b := New(2, 1)
+		b.SetMaxScrollback(0)
+		b.StartLink("", "https://example.com/kept")
+		b.WriteRune('a')
+		kept := b.Runes()[0].Link
+		for i := 0; i < maxLinks*2; i++ {
+			b.StartLink(strings.Repeat("a", i%10)+string(rune('a'+i%26)), "https://example.com/"+strings.Repeat("b", i/26))
+		}
+		if len(b.links.links) > maxLinks {
+			t.Fatalf("the link table should have at most %d links, but has %d", maxLinks, len(b.links.links))
+		}
+		if _, ok := b.Link(kept); !ok {
+			t.Fatalf("the link that is on the screen should stay in the table")
+		}
+	})
+
+	t.Run("links survive scrolling into the scrollback", func(t *testing.T) {
+		b := New(2, 1)
+		b.StartLink("", "https://example.com")
+		b.WriteRune('a')
+		b.EndLink()
+		b.LF()
+		b.ScrollView(1)
+		l, ok := b.Link(b.Runes()[0].Link)
+		if !ok || l.URI != "https://example.com" {
+			t.Fatalf("the character in the scrollback should reference the link, got %v", l)
+		}
+	})
+}
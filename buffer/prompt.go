@@ -0,0 +1,115 @@
+package buffer
+
+import "strings"
+
+// LineMarks are the semantic marks that the shell puts on lines with OSC 133 (shell integration)
+// https://gitlab.freedesktop.org/Per_Bothner/specifications/blob/master/proposals/semantic-prompts.md
+type LineMarks uint8
+
+const (
+	// MarkPrompt is on the line where the shell prompt starts
+	MarkPrompt LineMarks = 1 << iota
+	// MarkCommand is on the line where the user started typing the command
+	MarkCommand
+	// MarkOutput is on the line where the command output starts
+	MarkOutput
+	// MarkFailed is on the prompt line of a command that exited with a non-zero code
+	MarkFailed
+)
+
+// Mark adds the mark to the cursor line
+func (b *Buffer) Mark(m LineMarks) {
+	b.lines[b.cursor.Y].marks |= m
+	b.damageLine(b.cursor.Y)
+}
+
+// CommandFinished marks the prompt of the last command as failed if the exit code isn't 0
+func (b *Buffer) CommandFinished(exitCode int) {
+	if exitCode == 0 {
+		return
+	}
+	if i, ok := b.findMark(MarkPrompt, b.cursorHistoryIndex(), -1); ok {
+		b.historyLine(i).marks |= MarkFailed
+		b.damageHistoryLine(i)
+	}
+}
+
+// LineMarks returns the marks of every line in the view
+func (b *Buffer) LineMarks() []LineMarks {
+	lines := b.viewLines()
+	marks := make([]LineMarks, len(lines))
+	for i, l := range lines {
+		marks[i] = l.marks
+	}
+	return marks
+}
+
+// PreviousPrompt scrolls the view up so the closest prompt above the view top is the first line in the view
+func (b *Buffer) PreviousPrompt() {
+	if i, ok := b.findMark(MarkPrompt, b.viewTop()-1, -1); ok {
+		b.scrollViewTo(i)
+	}
+}
+
+// NextPrompt scrolls the view down so the closest prompt below the view top is the first line in the view
+func (b *Buffer) NextPrompt() {
+	if i, ok := b.findMark(MarkPrompt, b.viewTop()+1, 1); ok {
+		b.scrollViewTo(i)
+	} else {
+		b.ResetView()
+	}
+}
+
+// LastCommandOutput returns the text between the last output mark and the following prompt
+// lines that wrapped because they were too long are joined back together
+func (b *Buffer) LastCommandOutput() string {
+	start, ok := b.findMark(MarkOutput, b.cursorHistoryIndex(), -1)
+	// if the next prompt starts on the output line, the command didn't print anything
+	if !ok || b.historyLine(start).marks&MarkPrompt != 0 {
+		return ""
+	}
+	end, ok := b.findMark(MarkPrompt, start+1, 1)
+	if !ok {
+		end = b.cursorHistoryIndex() + 1
+	}
+	// logical is the line before the terminal wrapped it
+	var out, logical strings.Builder
+	for i := start; i < end; i++ {
+		l := b.historyLine(i)
+		for _, c := range l.cells {
+			logical.WriteRune(c.R)
+		}
+		if !l.wrapped {
+			// trailing spaces are the empty cells
+			out.WriteString(strings.TrimRight(logical.String(), " "))
+			out.WriteRune('\n')
+			logical.Reset()
+		}
+	}
+	out.WriteString(strings.TrimRight(logical.String(), " "))
+	return strings.TrimRight(out.String(), "\n")
+}
+
+// findMark returns the history index of the first line with the mark, starting at the from index and moving in the direction (1 or -1)
+func (b *Buffer) findMark(m LineMarks, from, direction int) (int, bool) {
+	for i := from; i >= 0 && i < b.historyLen(); i += direction {
+		if b.historyLine(i).marks&m != 0 {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// cursorHistoryIndex returns the history index of the cursor line
+func (b *Buffer) cursorHistoryIndex() int {
+	return len(b.scrollback) + b.cursor.Y
+}
+
+// scrollViewTo scrolls the view so the line with history index i is the first line in the view
+// if the line is on the screen, the view goes back to the screen
+func (b *Buffer) scrollViewTo(i int) {
+	if b.bufferType != bufPrimary {
+		return
+	}
+	b.setViewOffset(clamp(len(b.scrollback)-i, 0, len(b.scrollback)))
+}
@@ -0,0 +1,93 @@
+package buffer
+
+import "testing"
+
+// writeLine writes the text on the cursor line and moves the cursor to the start of the next line
+func writeLine(b *Buffer, text string) {
+	for _, r := range text {
+		b.WriteRune(r)
+	}
+	b.CR()
+	b.LF()
+}
+
+func TestPromptMarks(t *testing.T) {
+	// makePromptBuffer simulates the shell running "ls" followed by a new prompt
+	makePromptBuffer := func(exitCode int) *Buffer {
+		b := New(5, 3)
+		b.Mark(MarkPrompt)
+		b.WriteRune('$')
+		b.Mark(MarkCommand)
+		writeLine(b, "ls")
+		b.Mark(MarkOutput)
+		writeLine(b, "a")
+		writeLine(b, "b")
+		b.CommandFinished(exitCode)
+		b.Mark(MarkPrompt)
+		b.WriteRune('$')
+		return b
+	}
+
+	t.Run("returns the output of the last command", func(t *testing.T) {
+		b := makePromptBuffer(0)
+		if out := b.LastCommandOutput(); out != "a\nb" {
+			t.Fatalf("the output should have been %q, but was %q", "a\nb", out)
+		}
+	})
+
+	t.Run("joins wrapped lines in the output", func(t *testing.T) {
+		b := New(3, 3)
+		b.Mark(MarkOutput)
+		writeLine(b, "abcde")
+		b.Mark(MarkPrompt)
+		if out := b.LastCommandOutput(); out != "abcde" {
+			t.Fatalf("the output should have been %q, but was %q", "abcde", out)
+		}
+	})
+
+	t.Run("marks the prompt of a failed command", func(t *testing.T) {
+		b := makePromptBuffer(1)
+		b.ScrollView(1)
+		if marks := b.LineMarks(); marks[0]&MarkFailed == 0 {
+			t.Fatalf("the prompt of the failed command should be marked, the marks are %v", marks)
+		}
+		b = makePromptBuffer(0)
+		b.ScrollView(1)
+		if marks := b.LineMarks(); marks[0]&MarkFailed != 0 {
+			t.Fatalf("the prompt of the successful command shouldn't be marked, the marks are %v", marks)
+		}
+	})
+
+	t.Run("jumps between prompts", func(t *testing.T) {
+		b := makePromptBuffer(0)
+		for i := 0; i < 3; i++ {
+			writeLine(b, "")
+		}
+		// the first prompt is 4 lines up in the scrollback, the second one is 1 line up
+		b.PreviousPrompt()
+		if b.ViewOffset() != 1 {
+			t.Fatalf("the view should show the second prompt on top, but the offset is %d", b.ViewOffset())
+		}
+		b.PreviousPrompt()
+		if b.ViewOffset() != 4 {
+			t.Fatalf("the view should show the first prompt on top, but the offset is %d", b.ViewOffset())
+		}
+		b.NextPrompt()
+		if b.ViewOffset() != 1 {
+			t.Fatalf("the view should go back to the second prompt, but the offset is %d", b.ViewOffset())
+		}
+		b.NextPrompt()
+		if b.ViewOffset() != 0 {
+			t.Fatalf("the view should go back to the screen, but the offset is %d", b.ViewOffset())
+		}
+	})
+
+	t.Run("erasing lines removes the marks", func(t *testing.T) {
+		b := New(3, 1)
+		b.Mark(MarkPrompt)
+		b.ClearLines(0, 1)
+		if b.LineMarks()[0] != 0 {
+			t.Fatalf("the erased line shouldn't have any marks")
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package buffer
+
+// Rect is a rectangular area of the screen in absolute coordinates (ignoring the origin mode).
+// Top and Left are indexes of the first row and column, Bottom and Right are
+// indexes of the last row and column + 1 (the same as the scroll area).
+type Rect struct {
+	Top, Left, Bottom, Right int
+}
+
+// Empty returns true if the rectangle doesn't contain any cell
+func (r Rect) Empty() bool {
+	return r.Top >= r.Bottom || r.Left >= r.Right
+}
+
+// Contains returns true if the cell on the row and column is inside the rectangle
+func (r Rect) Contains(col, row int) bool {
+	return row >= r.Top && row < r.Bottom && col >= r.Left && col < r.Right
+}
+
+// RectFromOrigin makes a rectangle from coordinates that are relative to the origin.
+// The origin is the top left corner of the screen or the top left corner of the margins if the origin mode is enabled.
+// The rectangle is clamped so it doesn't overflow the screen (or the margins in origin mode).
+func (b *Buffer) RectFromOrigin(top, left, bottom, right int) Rect {
+	return Rect{
+		Top:    clamp(top+b.minY(), b.minY(), b.maxY()),
+		Left:   clamp(left+b.minX(), b.minX(), b.maxX()),
+		Bottom: clamp(bottom+b.minY(), b.minY(), b.maxY()),
+		Right:  clamp(right+b.minX(), b.minX(), b.maxX()),
+	}
+}
+
+// clampRect makes sure that the rectangle fits on the screen
+func (b *Buffer) clampRect(r Rect) Rect {
+	return Rect{
+		Top:    clamp(r.Top, 0, b.size.Rows),
+		Left:   clamp(r.Left, 0, b.size.Cols),
+		Bottom: clamp(r.Bottom, 0, b.size.Rows),
+		Right:  clamp(r.Right, 0, b.size.Cols),
+	}
+}
+
+// CopyRect copies the characters from the src rectangle so that the top left corner of the copy is on dst.Top and dst.Left
+// the copy is cut so it fits into the dst rectangle
+// DECCRA - Copy Rectangular Area https://vt100.net/docs/vt510-rm/DECCRA.html
+func (b *Buffer) CopyRect(src, dst Rect) {
+	src = b.clampRect(src)
+	dst = b.clampRect(dst)
+	rows := min(src.Bottom-src.Top, dst.Bottom-dst.Top)
+	cols := min(src.Right-src.Left, dst.Right-dst.Left)
+	if rows <= 0 || cols <= 0 {
+		return
+	}
+	// copy the source first so that overlapping rectangles don't overwrite the source
+	copied := make([][]BrushedRune, rows)
+	for r := range copied {
+		copied[r] = make([]BrushedRune, cols)
+		copy(copied[r], b.lines[src.Top+r].cells[src.Left:src.Left+cols])
+	}
+	for r := range copied {
+		copy(b.lines[dst.Top+r].cells[dst.Left:], copied[r])
+	}
+	b.damageLines(dst.Top, dst.Top+rows)
+}
+
+// FillRect fills the rectangle with the rune r painted with the current brush
+// DECFRA - Fill Rectangular Area https://vt100.net/docs/vt510-rm/DECFRA.html
+func (b *Buffer) FillRect(rect Rect, r rune) {
+	rect = b.clampRect(rect)
+	for row := rect.Top; row < rect.Bottom; row++ {
+		for col := rect.Left; col < rect.Right; col++ {
+			b.lines[row].cells[col] = b.MakeRune(r)
+		}
+	}
+	b.damageLines(rect.Top, rect.Bottom)
+}
+
+// EraseRect replaces all characters in the rectangle with spaces
+// DECERA - Erase Rectangular Area https://vt100.net/docs/vt510-rm/DECERA.html
+func (b *Buffer) EraseRect(rect Rect) {
+	b.FillRect(rect, ' ')
+}
+
+// SelectiveEraseRect replaces characters in the rectangle with spaces, but it keeps the characters that are protected
+// DECSERA - Selective Erase Rectangular Area https://vt100.net/docs/vt510-rm/DECSERA.html
+func (b *Buffer) SelectiveEraseRect(rect Rect) {
+	rect = b.clampRect(rect)
+	for row := rect.Top; row < rect.Bottom; row++ {
+		for col := rect.Left; col < rect.Right; col++ {
+			if !b.lines[row].cells[col].Brush.Protected {
+				b.lines[row].cells[col] = b.MakeRune(' ')
+			}
+		}
+	}
+	b.damageLines(rect.Top, rect.Bottom)
+}
+
+// SetRectAttributeChangeExtent decides what cells ChangeRectAttributes changes.
+// true - only the cells in the rectangle, false (default) - all cells from the top left to the bottom right corner
+// as if they were a stream of text (wrapping lines)
+// DECSACE - Select Attribute Change Extent https://vt100.net/docs/vt510-rm/DECSACE.html
+func (b *Buffer) SetRectAttributeChangeExtent(rectangle bool) {
+	b.rectAttributeChange = rectangle
+}
+
+// ChangeRectAttributes replaces the brush of every character in the rectangle with the result of the change function.
+// It's used for both DECCARA (Change Attributes in Rectangular Area) and DECRARA (Reverse Attributes in Rectangular Area)
+// https://vt100.net/docs/vt510-rm/DECCARA.html
+func (b *Buffer) ChangeRectAttributes(rect Rect, change func(Brush) Brush) {
+	rect = b.clampRect(rect)
+	if rect.Empty() {
+		return
+	}
+	b.damageLines(rect.Top, rect.Bottom)
+	for row := rect.Top; row < rect.Bottom; row++ {
+		start, end := rect.Left, rect.Right
+		if !b.rectAttributeChange {
+			// in the stream mode, the first line starts in the left column and the last ends in the right one
+			// but all lines in between are changed whole
+			if row != rect.Top {
+				start = 0
+			}
+			if row != rect.Bottom-1 {
+				end = b.size.Cols
+			}
+		}
+		for col := start; col < end; col++ {
+			b.lines[row].cells[col].Brush = change(b.lines[row].cells[col].Brush)
+		}
+	}
+}
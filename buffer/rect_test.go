@@ -0,0 +1,142 @@
+package buffer
+
+import "testing"
+
+func TestCopyRect(t *testing.T) {
+	t.Run("copies rectangle", func(t *testing.T) {
+		b := makeTestBuffer(t, `
+		ab__
+		cd__
+		____
+		`, 0, 0)
+		expected := trimExpectation(t, `
+		ab__
+		cdab
+		__cd
+		`)
+		b.CopyRect(Rect{Top: 0, Left: 0, Bottom: 2, Right: 2}, Rect{Top: 1, Left: 2, Bottom: 3, Right: 4})
+		if b.String() != expected {
+			t.Fatalf("CopyRect didn't copy the rectangle\nExpected:\n%s\nGot:\n%s", expected, b.String())
+		}
+	})
+
+	t.Run("copies overlapping rectangle", func(t *testing.T) {
+		b := makeTestBuffer(t, `
+		abc_
+		def_
+		`, 0, 0)
+		expected := trimExpectation(t, `
+		aabc
+		ddef
+		`)
+		b.CopyRect(Rect{Top: 0, Left: 0, Bottom: 2, Right: 3}, Rect{Top: 0, Left: 1, Bottom: 2, Right: 4})
+		if b.String() != expected {
+			t.Fatalf("CopyRect didn't copy the overlapping rectangle\nExpected:\n%s\nGot:\n%s", expected, b.String())
+		}
+	})
+
+	t.Run("cuts the copy at the screen edge", func(t *testing.T) {
+		b := makeTestBuffer(t, `
+		ab_
+		cd_
+		`, 0, 0)
+		expected := trimExpectation(t, `
+		ab_
+		cda
+		`)
+		b.CopyRect(Rect{Top: 0, Left: 0, Bottom: 2, Right: 2}, Rect{Top: 1, Left: 2, Bottom: 10, Right: 10})
+		if b.String() != expected {
+			t.Fatalf("CopyRect didn't cut the copied rectangle\nExpected:\n%s\nGot:\n%s", expected, b.String())
+		}
+	})
+}
+
+func TestFillAndEraseRect(t *testing.T) {
+	b := makeTestBuffer(t, `
+	abcd
+	efgh
+	ijkl
+	`, 0, 0)
+	b.FillRect(Rect{Top: 0, Left: 1, Bottom: 2, Right: 3}, 'x')
+	expected := trimExpectation(t, `
+	axxd
+	exxh
+	ijkl
+	`)
+	if b.String() != expected {
+		t.Fatalf("FillRect didn't fill the rectangle\nExpected:\n%s\nGot:\n%s", expected, b.String())
+	}
+	b.EraseRect(Rect{Top: 1, Left: 0, Bottom: 3, Right: 2})
+	expected = trimExpectation(t, `
+	axxd
+	__xh
+	__kl
+	`)
+	if b.String() != expected {
+		t.Fatalf("EraseRect didn't erase the rectangle\nExpected:\n%s\nGot:\n%s", expected, b.String())
+	}
+}
+
+func TestSelectiveEraseRect(t *testing.T) {
+	b := New(4, 1)
+	b.WriteRune('a')
+	b.SetBrush(Brush{Protected: true})
+	b.WriteRune('b')
+	b.SetBrush(Brush{})
+	b.WriteRune('c')
+	b.SelectiveEraseRect(Rect{Top: 0, Left: 0, Bottom: 1, Right: 4})
+	if b.String() != trimExpectation(t, "_b__") {
+		t.Fatalf("SelectiveEraseRect should have kept the protected character, got:\n%q", b.String())
+	}
+}
+
+func TestChangeRectAttributes(t *testing.T) {
+	bold := func(br Brush) Brush {
+		br.Bold = true
+		return br
+	}
+	boldCells := func(b *Buffer) string {
+		var s string
+		for _, l := range b.lines {
+			for _, c := range l.cells {
+				if c.Brush.Bold {
+					s += "b"
+				} else {
+					s += "_"
+				}
+			}
+			s += "\n"
+		}
+		return s
+	}
+
+	t.Run("changes a stream of characters by default", func(t *testing.T) {
+		b := New(3, 3)
+		b.ChangeRectAttributes(Rect{Top: 0, Left: 1, Bottom: 3, Right: 2}, bold)
+		expected := "_bb\nbbb\nbb_\n"
+		if boldCells(b) != expected {
+			t.Fatalf("ChangeRectAttributes should have changed the stream of characters\nExpected:\n%s\nGot:\n%s", expected, boldCells(b))
+		}
+	})
+
+	t.Run("changes only the rectangle with rectangle extent", func(t *testing.T) {
+		b := New(3, 3)
+		b.SetRectAttributeChangeExtent(true)
+		b.ChangeRectAttributes(Rect{Top: 0, Left: 1, Bottom: 3, Right: 2}, bold)
+		expected := "_b_\n_b_\n_b_\n"
+		if boldCells(b) != expected {
+			t.Fatalf("ChangeRectAttributes should have changed only the rectangle\nExpected:\n%s\nGot:\n%s", expected, boldCells(b))
+		}
+	})
+}
+
+func TestRectFromOrigin(t *testing.T) {
+	b := New(5, 5)
+	b.SetScrollArea(1, 4)
+	b.SetOriginMode(true)
+	r := b.RectFromOrigin(0, 0, 10, 10)
+	expected := Rect{Top: 1, Left: 0, Bottom: 4, Right: 5}
+	if r != expected {
+		t.Fatalf("the rectangle should be relative to the margins and clamped %v, but was %v", expected, r)
+	}
+}
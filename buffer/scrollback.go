@@ -0,0 +1,107 @@
+package buffer
+
+// DefaultScrollback is the number of lines kept in the scrollback of a new buffer
+const DefaultScrollback = 1000
+
+// pushScrollback stores lines that scrolled off the top of the primary screen
+// if the view is scrolled up, it stays on the same content
+func (b *Buffer) pushScrollback(lines []line) {
+	if b.bufferType != bufPrimary || b.maxScrollback == 0 {
+		return
+	}
+	b.scrollback = append(b.scrollback, lines...)
+	if b.viewOffset > 0 {
+		b.setViewOffset(b.viewOffset + len(lines))
+	}
+	b.trimScrollback()
+}
+
+// trimScrollback removes the oldest lines that don't fit into the scrollback
+// it only moves the start of the slice, the kept lines get copied when append runs
+// out of capacity, which happens once per a fraction of maxScrollback pushed lines
+func (b *Buffer) trimScrollback() {
+	if excess := len(b.scrollback) - b.maxScrollback; excess > 0 {
+		// the backing array keeps the removed lines until append reallocates it, release their cells now
+		clear(b.scrollback[:excess])
+		b.scrollback = b.scrollback[excess:]
+	}
+	b.setViewOffset(min(b.viewOffset, len(b.scrollback)))
+}
+
+// SetMaxScrollback changes how many lines can the scrollback contain, 0 disables the scrollback
+func (b *Buffer) SetMaxScrollback(lines int) {
+	b.maxScrollback = max(lines, 0)
+	b.trimScrollback()
+}
+
+// ScrollbackLen returns the number of lines in the scrollback
+func (b *Buffer) ScrollbackLen() int {
+	return len(b.scrollback)
+}
+
+// ScrollView moves the view n lines up into the scrollback, negative n moves the view down towards the screen
+// this is how the links kept in the scrollback get back under the pointer so they can be hovered and clicked
+// the alternate screen has no scrollback so its view can't move
+func (b *Buffer) ScrollView(n int) {
+	if b.bufferType != bufPrimary {
+		return
+	}
+	b.setViewOffset(clamp(b.viewOffset+n, 0, len(b.scrollback)))
+}
+
+// ResetView moves the view back to the screen
+func (b *Buffer) ResetView() {
+	b.setViewOffset(0)
+}
+
+// setViewOffset scrolls the view, all lines in the view change when it moves
+func (b *Buffer) setViewOffset(offset int) {
+	if offset != b.viewOffset {
+		b.viewOffset = offset
+		b.damageAll()
+	}
+}
+
+// ViewOffset returns how many lines is the view scrolled up into the scrollback
+func (b *Buffer) ViewOffset() int {
+	return b.viewOffset
+}
+
+// viewLines returns the lines visible in the view, the view is made of the end
+// of the scrollback and the top of the screen when it's scrolled up
+func (b *Buffer) viewLines() []line {
+	if b.viewOffset == 0 {
+		return b.lines
+	}
+	top := b.viewTop()
+	lines := make([]line, 0, b.size.Rows)
+	for i := top; i < top+b.size.Rows; i++ {
+		lines = append(lines, *b.historyLine(i))
+	}
+	return lines
+}
+
+// viewTop returns the history index (see historyLine) of the first line in the view
+func (b *Buffer) viewTop() int {
+	return len(b.scrollback) - b.viewOffset
+}
+
+// historyLen returns the number of lines in the scrollback and on the screen
+func (b *Buffer) historyLen() int {
+	return len(b.scrollback) + len(b.lines)
+}
+
+// historyLine returns the line on index i, where the scrollback lines go first and the screen lines follow them
+func (b *Buffer) historyLine(i int) *line {
+	if i < len(b.scrollback) {
+		return &b.scrollback[i]
+	}
+	return &b.lines[i-len(b.scrollback)]
+}
+
+// ViewCursor returns the cursor position in the view, it returns false if
+// the view is scrolled up so much that the cursor isn't in it
+func (b *Buffer) ViewCursor() (Cursor, bool) {
+	c := Cursor{X: b.cursor.X, Y: b.cursor.Y + b.viewOffset}
+	return c, c.Y < b.size.Rows
+}
@@ -0,0 +1,141 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+)
+
+// viewString returns the characters in the view in the same format as Buffer.String
+func viewString(b *Buffer) string {
+	var sb strings.Builder
+	for i, r := range b.Runes() {
+		sb.WriteRune(r.R)
+		if (i+1)%b.size.Cols == 0 {
+			sb.WriteRune('\n')
+		}
+	}
+	return sb.String()
+}
+
+func TestScrollback(t *testing.T) {
+	t.Run("lines scrolled off the screen go to the scrollback", func(t *testing.T) {
+		b := makeTestBuffer(t, `
+		abc
+		def
+		`, 0, 1)
+		b.LF()
+		b.LF()
+		if b.ScrollbackLen() != 2 {
+			t.Fatalf("scrollback should contain 2 lines, but contains %d", b.ScrollbackLen())
+		}
+		b.ScrollView(1)
+		expected := trimExpectation(t, `
+		def
+		___
+		`)
+		if viewString(b) != expected {
+			t.Fatalf("the view is incorrect\nExpected:\n%s\nGot:\n%s", expected, viewString(b))
+		}
+		b.ScrollView(5)
+		expected = trimExpectation(t, `
+		abc
+		def
+		`)
+		if viewString(b) != expected {
+			t.Fatalf("the view should stop at the start of the scrollback\nExpected:\n%s\nGot:\n%s", expected, viewString(b))
+		}
+		b.ResetView()
+		if viewString(b) != b.String() {
+			t.Fatalf("the view should show the screen after reset\nExpected:\n%s\nGot:\n%s", b.String(), viewString(b))
+		}
+	})
+
+	t.Run("scrolling inside a scroll area doesn't fill the scrollback", func(t *testing.T) {
+		b := makeTestBuffer(t, `
+		abc
+		def
+		ghi
+		`, 0, 2)
+		b.SetScrollArea(1, 3)
+		b.SetCursor(0, 2)
+		b.LF()
+		if b.ScrollbackLen() != 0 {
+			t.Fatalf("scrollback should be empty, but contains %d lines", b.ScrollbackLen())
+		}
+	})
+
+	t.Run("the alternate screen doesn't fill the scrollback", func(t *testing.T) {
+		b := New(3, 1)
+		b.SwitchToAlternateBuffer()
+		b.LF()
+		if b.ScrollbackLen() != 0 {
+			t.Fatalf("scrollback should be empty, but contains %d lines", b.ScrollbackLen())
+		}
+	})
+
+	t.Run("keeps only the maximum number of lines", func(t *testing.T) {
+		b := New(3, 1)
+		b.SetMaxScrollback(2)
+		for _, r := range "abc" {
+			b.WriteRune(r)
+			b.CR()
+			b.LF()
+		}
+		b.ScrollView(2)
+		expected := trimExpectation(t, `
+		b__
+		`)
+		if b.ScrollbackLen() != 2 || viewString(b) != expected {
+			t.Fatalf("scrollback should contain the last 2 lines\nExpected:\n%s\nGot:\n%s", expected, viewString(b))
+		}
+	})
+
+	t.Run("a full scrollback doesn't grow when more lines scroll in", func(t *testing.T) {
+		b := New(3, 1)
+		b.SetMaxScrollback(100)
+		for i := 0; i < 10000; i++ {
+			b.WriteRune(rune('a' + i%26))
+			b.CR()
+			b.LF()
+		}
+		if b.ScrollbackLen() != 100 || cap(b.scrollback) > 2*100 {
+			t.Fatalf("scrollback should contain 100 lines in at most twice as big array, but contains %d lines in array of %d", b.ScrollbackLen(), cap(b.scrollback))
+		}
+		b.ScrollView(100)
+		// the screen has the empty line after the last LF, the oldest kept line is line 9900 ('a' + 9900%26)
+		expected := trimExpectation(t, `
+		u__
+		`)
+		if viewString(b) != expected {
+			t.Fatalf("the view should start with the oldest kept line\nExpected:\n%s\nGot:\n%s", expected, viewString(b))
+		}
+	})
+
+	t.Run("the view stays on the same lines when new lines scroll in", func(t *testing.T) {
+		b := New(3, 1)
+		b.WriteRune('a')
+		b.LF()
+		b.ScrollView(1)
+		b.LF()
+		expected := trimExpectation(t, `
+		a__
+		`)
+		if viewString(b) != expected {
+			t.Fatalf("the view moved\nExpected:\n%s\nGot:\n%s", expected, viewString(b))
+		}
+	})
+
+	t.Run("the cursor moves down with the view", func(t *testing.T) {
+		b := New(3, 2)
+		b.LF()
+		b.LF()
+		b.ScrollView(1)
+		if c, ok := b.ViewCursor(); ok || c != (Cursor{X: 0, Y: 2}) {
+			t.Fatalf("the cursor should be below the view, but was %v (visible: %v)", c, ok)
+		}
+		b.SetCursor(0, 0)
+		if c, ok := b.ViewCursor(); !ok || c != (Cursor{X: 0, Y: 1}) {
+			t.Fatalf("the cursor should be on the second line of the view, but was %v (visible: %v)", c, ok)
+		}
+	})
+}
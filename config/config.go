@@ -0,0 +1,281 @@
+// Package config loads the gritty configuration file
+//
+// The file is in the TOML format and it's stored in $XDG_CONFIG_HOME/gritty/config.toml
+// (~/.config/gritty/config.toml on Linux). All options are optional, see Default for the default values.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/BurntSushi/toml"
+	"github.com/viktomas/gritty/buffer"
+	"github.com/viktomas/gritty/controller"
+)
+
+// FileName is the name of the config file in the gritty config directory
+const FileName = "config.toml"
+
+const (
+	minFontSize = 4
+	maxFontSize = 200
+	// maxScrollback is the largest scrollback we allow, it protects the user from running out of memory because of a typo
+	maxScrollback = 1_000_000
+)
+
+type Config struct {
+	Shell Shell `toml:"shell"`
+	// Env are environment variables added to the shell environment, they replace the variables inherited from gritty
+	Env map[string]string `toml:"env"`
+	// UnsetEnv are names of the variables inherited from gritty that the shell shouldn't see
+	UnsetEnv []string `toml:"unset_env"`
+	Font     Font     `toml:"font"`
+	// Colors override the default theme
+	Colors Colors `toml:"colors"`
+	// Scrollback is the maximum number of lines in the scrollback, 0 disables the scrollback
+	Scrollback int       `toml:"scrollback"`
+	Cursor     Cursor    `toml:"cursor"`
+	Clipboard  Clipboard `toml:"clipboard"`
+	Bell       Bell      `toml:"bell"`
+	Links      Links     `toml:"links"`
+	// Title is a text/template for the window title, it can use {{.Title}} (set by the program) and {{.Cwd}}
+	Title string `toml:"title"`
+	// Keybindings map keys (e.g. "Ctrl+Shift+Z") to actions, they are added to the DefaultKeybindings,
+	// the "none" action removes the default binding so the key goes to the program
+	Keybindings map[string]string `toml:"keybindings"`
+}
+
+type Shell struct {
+	Program string   `toml:"program"`
+	Args    []string `toml:"args"`
+}
+
+type Font struct {
+	// Family is a comma separated list of font families, the first available family is used
+	Family string  `toml:"family"`
+	Size   float32 `toml:"size"`
+}
+
+// Colors are in the #rrggbb format, empty colors are taken from the default palette
+type Colors struct {
+	Foreground string `toml:"foreground"`
+	Background string `toml:"background"`
+	Cursor     string `toml:"cursor"`
+	// Palette overrides the indexed colors starting from color 0, it usually contains the 16 basic colors
+	Palette []string `toml:"palette"`
+}
+
+type Cursor struct {
+	// Style is block, underline or bar
+	Style    string `toml:"style"`
+	Blinking bool   `toml:"blinking"`
+}
+
+type Clipboard struct {
+	// AllowRead lets programs read the clipboard with OSC 52
+	AllowRead bool `toml:"allow_read"`
+}
+
+type Bell struct {
+	Visual  bool     `toml:"visual"`
+	Urgent  bool     `toml:"urgent"`
+	Command []string `toml:"command"`
+}
+
+type Links struct {
+	// Opener is the command that opens hyperlinks, the URI is passed to it as the last argument
+	Opener []string `toml:"opener"`
+}
+
+// Default returns the configuration used when the config file doesn't exist
+func Default() Config {
+	return Config{
+		Shell:      Shell{Program: "/bin/sh"},
+		Font:       Font{Family: "go mono, monospaced", Size: 16},
+		Scrollback: buffer.DefaultScrollback,
+		Cursor:     Cursor{Style: "block", Blinking: true},
+		Bell:       Bell{Visual: true, Urgent: true},
+		Title:      `{{or .Title "Gritty"}}`,
+	}
+}
+
+// Path returns the path to the config file in the user config directory
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("can't find the config directory: %w", err)
+	}
+	return filepath.Join(dir, "gritty", FileName), nil
+}
+
+// Load reads the config file, if the file doesn't exist, it returns the default config
+// it's meant for the default path (see Path) which doesn't have to exist, use LoadFile for paths that the user chose
+func Load(path string) (Config, error) {
+	cfg, err := LoadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Default(), nil
+	}
+	return cfg, err
+}
+
+// LoadFile reads the config file, unlike Load, it fails if the file doesn't exist
+func LoadFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse reads the config from TOML, options missing in the TOML have the default value
+func Parse(data []byte) (Config, error) {
+	cfg := Default()
+	md, err := toml.Decode(string(data), &cfg)
+	if err != nil {
+		return Config{}, err
+	}
+	var errs []error
+	for _, k := range md.Undecoded() {
+		// keybindings and env are maps, so all their keys get decoded
+		errs = append(errs, fmt.Errorf("unknown option %q", k.String()))
+	}
+	errs = append(errs, cfg.validate()...)
+	if len(errs) > 0 {
+		return Config{}, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// validate returns an error for every invalid option
+func (c Config) validate() []error {
+	var errs []error
+	if c.Shell.Program == "" {
+		errs = append(errs, errors.New("shell.program can't be empty"))
+	}
+	for k := range c.Env {
+		if k == "" || strings.ContainsAny(k, "=\x00") {
+			errs = append(errs, fmt.Errorf("env: invalid variable name %q", k))
+		}
+	}
+	for _, k := range c.UnsetEnv {
+		if k == "" || strings.ContainsAny(k, "=\x00") {
+			errs = append(errs, fmt.Errorf("unset_env: invalid variable name %q", k))
+		}
+	}
+	if c.Font.Size < minFontSize || c.Font.Size > maxFontSize {
+		errs = append(errs, fmt.Errorf("font.size must be between %d and %d, but it is %v", minFontSize, maxFontSize, c.Font.Size))
+	}
+	if strings.TrimSpace(c.Font.Family) == "" {
+		errs = append(errs, errors.New("font.family can't be empty"))
+	}
+	for name, color := range map[string]string{"colors.foreground": c.Colors.Foreground, "colors.background": c.Colors.Background, "colors.cursor": c.Colors.Cursor} {
+		if _, err := parseColor(color); color != "" && err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	if len(c.Colors.Palette) > 256 {
+		errs = append(errs, fmt.Errorf("colors.palette can contain at most 256 colors, but it contains %d", len(c.Colors.Palette)))
+	}
+	for i, color := range c.Colors.Palette {
+		if _, err := parseColor(color); err != nil {
+			errs = append(errs, fmt.Errorf("colors.palette[%d]: %w", i, err))
+		}
+	}
+	if c.Scrollback < 0 || c.Scrollback > maxScrollback {
+		errs = append(errs, fmt.Errorf("scrollback must be between 0 and %d, but it is %d", maxScrollback, c.Scrollback))
+	}
+	if _, ok := cursorShapes[c.Cursor.Style]; !ok {
+		errs = append(errs, fmt.Errorf("cursor.style must be block, underline or bar, but it is %q", c.Cursor.Style))
+	}
+	if _, err := template.New("title").Parse(c.Title); err != nil {
+		errs = append(errs, fmt.Errorf("title: %w", err))
+	}
+	for k, action := range c.Keybindings {
+		if _, err := ParseKey(k); err != nil {
+			errs = append(errs, fmt.Errorf("keybindings: %w", err))
+		}
+		if _, ok := actions[Action(action)]; !ok {
+			errs = append(errs, fmt.Errorf("keybindings: unknown action %q for %q, valid actions are %s", action, k, actionNames()))
+		}
+	}
+	return errs
+}
+
+var cursorShapes = map[string]buffer.CursorShape{
+	"block":     buffer.CursorBlock,
+	"underline": buffer.CursorUnderline,
+	"bar":       buffer.CursorBar,
+}
+
+// parseColor parses the #rrggbb color
+func parseColor(s string) (buffer.RGB, error) {
+	if len(s) != 7 || s[0] != '#' {
+		return buffer.RGB{}, fmt.Errorf("invalid color %q, expected #rrggbb", s)
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return buffer.RGB{}, fmt.Errorf("invalid color %q, expected #rrggbb", s)
+	}
+	return buffer.RGB{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v)}, nil
+}
+
+// Palette returns the default palette with the configured colors
+func (c Config) Palette() buffer.Palette {
+	p := buffer.DefaultPalette()
+	set := func(dst *buffer.RGB, color string) {
+		if rgb, err := parseColor(color); err == nil {
+			*dst = rgb
+		}
+	}
+	set(&p.FG, c.Colors.Foreground)
+	// the cursor has the foreground color unless it's configured
+	set(&p.Cursor, c.Colors.Foreground)
+	set(&p.Cursor, c.Colors.Cursor)
+	set(&p.BG, c.Colors.Background)
+	for i, color := range c.Colors.Palette {
+		if i < len(p.Indexed) {
+			set(&p.Indexed[i], color)
+		}
+	}
+	return p
+}
+
+// CursorStyle returns the configured default cursor style
+func (c Config) CursorStyle() buffer.CursorStyle {
+	return buffer.CursorStyle{Shape: cursorShapes[c.Cursor.Style], Blinking: c.Cursor.Blinking}
+}
+
+// Environ returns the configured environment variables in the key=value form
+func (c Config) Environ() []string {
+	env := make([]string, 0, len(c.Env))
+	for k, v := range c.Env {
+		env = append(env, k+"="+v)
+	}
+	slices.Sort(env)
+	return env
+}
+
+// ControllerOptions returns the options that the controller can change while the shell runs
+func (c Config) ControllerOptions() controller.Options {
+	return controller.Options{
+		Theme:              c.Palette(),
+		Scrollback:         c.Scrollback,
+		CursorStyle:        c.CursorStyle(),
+		AllowClipboardRead: c.Clipboard.AllowRead,
+		Bell: controller.Bell{
+			Visual:  c.Bell.Visual,
+			Urgent:  c.Bell.Urgent,
+			Command: c.Bell.Command,
+		},
+	}
+}
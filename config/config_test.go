@@ -0,0 +1,252 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/viktomas/gritty/buffer"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("empty config is the default config", func(t *testing.T) {
+		cfg, err := Parse(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(cfg, Default()) {
+			t.Fatalf("expected the default config %+v, got %+v", Default(), cfg)
+		}
+		if cfg.Palette() != buffer.DefaultPalette() {
+			t.Fatalf("the default config should use the default palette")
+		}
+	})
+
+	t.Run("parses all options", func(t *testing.T) {
+		cfg, err := Parse([]byte(`
+scrollback = 5000
+title = "{{.Cwd}}"
+unset_env = ["TMUX"]
+
+[shell]
+program = "/bin/zsh"
+args = ["-l"]
+
+[env]
+EDITOR = "vim"
+LANG = "en_US.UTF-8"
+
+[font]
+family = "JetBrains Mono"
+size = 14
+
+[colors]
+foreground = "#010203"
+background = "#ffffff"
+palette = ["#000000", "#ff0000"]
+
+[cursor]
+style = "bar"
+blinking = false
+
+[clipboard]
+allow_read = true
+
+[bell]
+visual = false
+command = ["paplay", "bell.oga"]
+
+[links]
+opener = ["firefox"]
+
+[keybindings]
+"ctrl+shift+c" = "copy_last_output"
+"Ctrl+Shift+Z" = "none"
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Shell.Program != "/bin/zsh" || !reflect.DeepEqual(cfg.Shell.Args, []string{"-l"}) {
+			t.Fatalf("unexpected shell %+v", cfg.Shell)
+		}
+		if env := cfg.Environ(); !reflect.DeepEqual(env, []string{"EDITOR=vim", "LANG=en_US.UTF-8"}) {
+			t.Fatalf("unexpected env %v", env)
+		}
+		if !reflect.DeepEqual(cfg.UnsetEnv, []string{"TMUX"}) {
+			t.Fatalf("unexpected unset_env %v", cfg.UnsetEnv)
+		}
+		if cfg.Font != (Font{Family: "JetBrains Mono", Size: 14}) {
+			t.Fatalf("unexpected font %+v", cfg.Font)
+		}
+		p := cfg.Palette()
+		if p.FG != (buffer.RGB{R: 1, G: 2, B: 3}) || p.Cursor != p.FG || p.BG != (buffer.RGB{R: 255, G: 255, B: 255}) || p.Indexed[1] != (buffer.RGB{R: 255}) {
+			t.Fatalf("unexpected palette fg %v, cursor %v, bg %v, red %v", p.FG, p.Cursor, p.BG, p.Indexed[1])
+		}
+		if p.Indexed[2] != buffer.DefaultPalette().Indexed[2] {
+			t.Fatalf("colors that aren't configured should stay the same")
+		}
+		if cfg.CursorStyle() != (buffer.CursorStyle{Shape: buffer.CursorBar}) {
+			t.Fatalf("unexpected cursor style %v", cfg.CursorStyle())
+		}
+		o := cfg.ControllerOptions()
+		if o.Scrollback != 5000 || !o.AllowClipboardRead || o.Bell.Visual || !o.Bell.Urgent || len(o.Bell.Command) != 2 {
+			t.Fatalf("unexpected controller options %+v", o)
+		}
+		bindings := cfg.Bindings()
+		if bindings[Key{Mods: ModCtrl | ModShift, Name: "C"}] != ActionCopyLastOutput {
+			t.Fatalf("the configured binding should be added")
+		}
+		if _, ok := bindings[Key{Mods: ModCtrl | ModShift, Name: "Z"}]; ok {
+			t.Fatalf("the none action should remove the default binding")
+		}
+		if bindings[Key{Mods: ModCtrl | ModShift, Name: "X"}] != ActionNextPrompt {
+			t.Fatalf("the default bindings should stay")
+		}
+	})
+
+	t.Run("reports all invalid options", func(t *testing.T) {
+		_, err := Parse([]byte(`
+scrollback = -1
+font_size = 12
+title = "{{.Title"
+unset_env = ["A=B"]
+
+[font]
+size = 1
+
+[colors]
+foreground = "red"
+palette = ["#00000g"]
+
+[cursor]
+style = "beam"
+
+[keybindings]
+"Hyper+A" = "new_window"
+"Ctrl+A" = "explode"
+`))
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		for _, expected := range []string{
+			`unknown option "font_size"`,
+			"scrollback must be between 0",
+			"title:",
+			`unset_env: invalid variable name "A=B"`,
+			"font.size must be between 4 and 200",
+			`colors.foreground: invalid color "red", expected #rrggbb`,
+			"colors.palette[0]",
+			`cursor.style must be block, underline or bar, but it is "beam"`,
+			`unknown modifier "Hyper"`,
+			`unknown action "explode"`,
+		} {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("the error should contain %q, but it is:\n%v", expected, err)
+			}
+		}
+	})
+
+	t.Run("reports the TOML syntax error", func(t *testing.T) {
+		_, err := Parse([]byte("scrollback = \n"))
+		if err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Fatalf("expected a syntax error on line 1, got %v", err)
+		}
+	})
+}
+
+func TestParseKey(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected Key
+	}{
+		{in: "a", expected: Key{Name: "A"}},
+		{in: "Ctrl+Shift+z", expected: Key{Mods: ModCtrl | ModShift, Name: "Z"}},
+		{in: "shift+pageup", expected: Key{Mods: ModShift, Name: "PageUp"}},
+		{in: "Alt+F12", expected: Key{Mods: ModAlt, Name: "F12"}},
+		{in: "Ctrl++", expected: Key{Mods: ModCtrl, Name: "+"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			k, err := ParseKey(tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if k != tc.expected {
+				t.Fatalf("expected %+v, got %+v", tc.expected, k)
+			}
+		})
+	}
+	for _, in := range []string{"", "Ctrl+", "Ctrl+Shift+Foo", "Meta+A"} {
+		if _, err := ParseKey(in); err == nil {
+			t.Errorf("key %q should be invalid", in)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("missing file is the default config", func(t *testing.T) {
+		cfg, err := Load(filepath.Join(t.TempDir(), FileName))
+		if err != nil || !reflect.DeepEqual(cfg, Default()) {
+			t.Fatalf("expected the default config, got %+v, %v", cfg, err)
+		}
+	})
+
+	t.Run("LoadFile fails for a missing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), FileName)
+		if _, err := LoadFile(path); !errors.Is(err, os.ErrNotExist) || !strings.Contains(err.Error(), path) {
+			t.Fatalf("the error should say that %s doesn't exist, got %v", path, err)
+		}
+	})
+
+	t.Run("the error contains the path", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), FileName)
+		if err := os.WriteFile(path, []byte("scrollback = -1"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), path) {
+			t.Fatalf("the error should contain the path, got %v", err)
+		}
+	})
+}
+
+func TestWatch(t *testing.T) {
+	// nextReload changes the file with change and returns the reload
+	nextReload := func(t *testing.T, reloads <-chan Reload, change func() error) Reload {
+		t.Helper()
+		if err := change(); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case r := <-reloads:
+			return r
+		case <-time.After(5 * watchInterval):
+			t.Fatal("the config should have been reloaded")
+		}
+		return Reload{}
+	}
+
+	for _, explicit := range []bool{false, true} {
+		t.Run(fmt.Sprintf("explicit %v", explicit), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), FileName)
+			done := make(chan struct{})
+			defer close(done)
+			reloads := Watch(path, explicit, done)
+			r := nextReload(t, reloads, func() error { return os.WriteFile(path, []byte("scrollback = 10"), 0o644) })
+			if r.Err != nil || r.Config.Scrollback != 10 {
+				t.Fatalf("expected the changed config, got %+v, %v", r.Config, r.Err)
+			}
+			r = nextReload(t, reloads, func() error { return os.Remove(path) })
+			if explicit && !errors.Is(r.Err, os.ErrNotExist) {
+				t.Fatalf("removing the explicit config should be an error, got %+v, %v", r.Config, r.Err)
+			}
+			if !explicit && (r.Err != nil || !reflect.DeepEqual(r.Config, Default())) {
+				t.Fatalf("removing the default config should reload the defaults, got %+v, %v", r.Config, r.Err)
+			}
+		})
+	}
+}
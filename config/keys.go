@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Modifiers are the modifier keys held with the key
+type Modifiers uint8
+
+const (
+	ModCtrl Modifiers = 1 << iota
+	ModShift
+	ModAlt
+	ModSuper
+)
+
+var modifierNames = map[string]Modifiers{
+	"ctrl":  ModCtrl,
+	"shift": ModShift,
+	"alt":   ModAlt,
+	"super": ModSuper,
+}
+
+// Key is a key with modifiers, e.g. Ctrl+Shift+Z
+type Key struct {
+	Mods Modifiers
+	// Name is an upper case letter, a digit, a punctuation character or one of the keyNames
+	Name string
+}
+
+// keyNames are the names of the keys that don't print a character
+var keyNames = []string{
+	"Up", "Down", "Left", "Right", "PageUp", "PageDown", "Home", "End",
+	"Delete", "Backspace", "Tab", "Enter", "Escape", "Space",
+	"F1", "F2", "F3", "F4", "F5", "F6", "F7", "F8", "F9", "F10", "F11", "F12",
+}
+
+// ParseKey parses keys like Ctrl+Shift+Z, the modifiers and the key names are case insensitive
+func ParseKey(s string) (Key, error) {
+	parts := strings.Split(s, "+")
+	// the last part can be the + key itself (e.g. Ctrl++)
+	if strings.HasSuffix(s, "++") {
+		parts = append(parts[:len(parts)-2], "+")
+	}
+	var k Key
+	for _, p := range parts[:len(parts)-1] {
+		m, ok := modifierNames[strings.ToLower(strings.TrimSpace(p))]
+		if !ok {
+			return Key{}, fmt.Errorf("invalid key %q, unknown modifier %q, valid modifiers are Ctrl, Shift, Alt and Super", s, p)
+		}
+		k.Mods |= m
+	}
+	name := strings.TrimSpace(parts[len(parts)-1])
+	if len([]rune(name)) == 1 && name != " " {
+		k.Name = strings.ToUpper(name)
+		return k, nil
+	}
+	for _, n := range keyNames {
+		if strings.EqualFold(n, name) {
+			k.Name = n
+			return k, nil
+		}
+	}
+	return Key{}, fmt.Errorf("invalid key %q, %q is not a character or one of %s", s, name, strings.Join(keyNames, ", "))
+}
+
+// Action is what gritty does when the user presses a bound key
+type Action string
+
+const (
+	// ActionNone removes a default binding, the key then goes to the program
+	ActionNone           Action = "none"
+	ActionScrollPageUp   Action = "scroll_page_up"
+	ActionScrollPageDown Action = "scroll_page_down"
+	ActionPreviousPrompt Action = "previous_prompt"
+	ActionNextPrompt     Action = "next_prompt"
+	ActionCopyLastOutput Action = "copy_last_output"
+	ActionNewWindow      Action = "new_window"
+)
+
+var actions = map[Action]bool{
+	ActionNone:           true,
+	ActionScrollPageUp:   true,
+	ActionScrollPageDown: true,
+	ActionPreviousPrompt: true,
+	ActionNextPrompt:     true,
+	ActionCopyLastOutput: true,
+	ActionNewWindow:      true,
+}
+
+func actionNames() string {
+	var names []string
+	for a := range actions {
+		names = append(names, string(a))
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+// DefaultKeybindings are the key bindings that the user can override in the config
+var DefaultKeybindings = map[Key]Action{
+	{Mods: ModShift, Name: "PageUp"}:      ActionScrollPageUp,
+	{Mods: ModShift, Name: "PageDown"}:    ActionScrollPageDown,
+	{Mods: ModCtrl | ModShift, Name: "Z"}: ActionPreviousPrompt,
+	{Mods: ModCtrl | ModShift, Name: "X"}: ActionNextPrompt,
+	{Mods: ModCtrl | ModShift, Name: "G"}: ActionCopyLastOutput,
+	{Mods: ModCtrl | ModShift, Name: "N"}: ActionNewWindow,
+}
+
+// Bindings returns the default key bindings combined with the configured ones
+func (c Config) Bindings() map[Key]Action {
+	bindings := map[Key]Action{}
+	for k, a := range DefaultKeybindings {
+		bindings[k] = a
+	}
+	for s, a := range c.Keybindings {
+		k, err := ParseKey(s)
+		if err != nil {
+			continue
+		}
+		if Action(a) == ActionNone {
+			delete(bindings, k)
+		} else {
+			bindings[k] = Action(a)
+		}
+	}
+	return bindings
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// watchInterval is how often Watch checks the config file for changes
+const watchInterval = time.Second
+
+// Reload is the result of loading the changed config file
+type Reload struct {
+	Config Config
+	// Err is the reason why the config couldn't be loaded, the previous config should stay in use
+	Err error
+}
+
+// Watch loads the config file every time it changes until done is closed.
+// The file is polled, so the editors that replace the file instead of writing into it are supported.
+// The explicit file (chosen with --config) is loaded with LoadFile, so removing it is an error and not the default config.
+func Watch(path string, explicit bool, done <-chan struct{}) <-chan Reload {
+	load := Load
+	if explicit {
+		load = LoadFile
+	}
+	reloads := make(chan Reload)
+	// the caller already loaded the current version
+	last := fileVersion(path)
+	go func() {
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			version := fileVersion(path)
+			if version == last {
+				continue
+			}
+			last = version
+			cfg, err := load(path)
+			select {
+			case reloads <- Reload{Config: cfg, Err: err}:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return reloads
+}
+
+// version identifies the content of the file without reading it
+type version struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func fileVersion(path string) version {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return version{}
+	}
+	return version{modTime: fi.ModTime(), size: fi.Size(), exists: true}
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+)
+
+// maxClipboardLength is the largest text (in bytes) that programs can copy to the clipboard with OSC 52
+const maxClipboardLength = 1 << 20
+
+// clipboardOSC handles OSC 52 ; Pc ; Pd where Pc selects the clipboard and Pd is the base64 encoded text.
+// If Pd is ?, the program asks for the clipboard content.
+// We don't distinguish between the selections in Pc, they all use the system clipboard.
+// https://invisible-island.net/xterm/ctlseqs/ctlseqs.html#h3-Operating-System-Commands
+func (c *Controller) clipboardOSC(pc, pd string) {
+	if pd == "?" {
+		if !c.AllowClipboardRead {
+			log.Println("ignoring clipboard read request (OSC 52), reading the clipboard is not allowed")
+			return
+		}
+		if pc == "" {
+			pc = "s0"
+		}
+		c.clipboardRead = pc
+		return
+	}
+	if base64.StdEncoding.DecodedLen(len(pd)) > maxClipboardLength {
+		log.Printf("ignoring clipboard write (OSC 52), the text is larger than %d bytes", maxClipboardLength)
+		return
+	}
+	text, err := base64.StdEncoding.DecodeString(pd)
+	if err != nil {
+		log.Printf("ignoring clipboard write (OSC 52), the text is not valid base64: %v", err)
+		return
+	}
+	s := string(text)
+	c.clipboard = &s
+}
+
+// TakeClipboard returns the text that the program copied with OSC 52 since the last call.
+// It returns false if the program didn't copy anything.
+func (c *Controller) TakeClipboard() (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.clipboard == nil {
+		return "", false
+	}
+	text := *c.clipboard
+	c.clipboard = nil
+	return text, true
+}
+
+// ClipboardReadRequested returns true if the program is waiting for the clipboard content, the GUI should answer with SendClipboard
+func (c *Controller) ClipboardReadRequested() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.clipboardRead != ""
+}
+
+// SendClipboard answers the OSC 52 read request with the clipboard content
+func (c *Controller) SendClipboard(text string) {
+	c.mu.Lock()
+	pc := c.clipboardRead
+	c.clipboardRead = ""
+	c.mu.Unlock()
+	if pc == "" {
+		return
+	}
+	if len(text) > maxClipboardLength {
+		text = ""
+	}
+	_, err := fmt.Fprintf(c.ptmx, "\x1b]52;%s;%s\x1b\\", pc, base64.StdEncoding.EncodeToString([]byte(text)))
+	if err != nil {
+		log.Printf("Error when writing clipboard content to PTY: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/viktomas/gritty/buffer"
+)
+
+func TestClipboard(t *testing.T) {
+	t.Run("copies decoded text", func(t *testing.T) {
+		c := &Controller{buffer: buffer.New(10, 10)}
+		handleInput(c, "\x1b]52;c;aGVsbG8=\x07")
+		text, ok := c.TakeClipboard()
+		if !ok || text != "hello" {
+			t.Fatalf("the clipboard should contain %q, but contains %q", "hello", text)
+		}
+		if _, ok := c.TakeClipboard(); ok {
+			t.Fatalf("the copied text should be taken only once")
+		}
+	})
+
+	t.Run("ignores invalid and too large text", func(t *testing.T) {
+		c := &Controller{buffer: buffer.New(10, 10)}
+		handleInput(c, "\x1b]52;c;not base64!\x07")
+		large := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", maxClipboardLength+1)))
+		handleInput(c, "\x1b]52;c;"+large+"\x07")
+		if text, ok := c.TakeClipboard(); ok {
+			t.Fatalf("the clipboard should be unchanged, but contains %q", text)
+		}
+	})
+
+	t.Run("ignores read requests unless they are allowed", func(t *testing.T) {
+		c := &Controller{buffer: buffer.New(10, 10)}
+		handleInput(c, "\x1b]52;c;?\x07")
+		if c.ClipboardReadRequested() {
+			t.Fatalf("the read request should be ignored")
+		}
+	})
+
+	t.Run("answers allowed read requests", func(t *testing.T) {
+		c, r := makePipeController(t)
+		c.AllowClipboardRead = true
+		handleInput(c, "\x1b]52;c;?\x07")
+		if !c.ClipboardReadRequested() {
+			t.Fatalf("the read request should be waiting for the GUI")
+		}
+		c.SendClipboard("hello")
+		if c.ClipboardReadRequested() {
+			t.Fatalf("the read request should be answered")
+		}
+		if reply := readReply(t, c, r); reply != "\x1b]52;c;aGVsbG8=\x1b\\" {
+			t.Fatalf("unexpected reply %q", reply)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/viktomas/gritty/parser"
+)
+
+// Command is the program that the controller runs in the PTY
+type Command struct {
+	Path string
+	Args []string
+	// Dir is the working directory of the program, empty means the gritty working directory
+	Dir string
+	// Env are environment variables in the key=value form, they replace the inherited variables
+	Env []string
+	// Unset are names of the inherited variables that the program shouldn't see
+	Unset []string
+}
+
+// exec returns the command ready to be started
+func (cmd Command) exec() *exec.Cmd {
+	c := exec.Command(cmd.Path, cmd.Args...)
+	c.Dir = cmd.Dir
+	c.Env = cmd.childEnv()
+	return c
+}
+
+// waitForExit waits for the program to exit, if the controller holds the screen, it prints the exit status on it
+func (c *Controller) waitForExit() {
+	if c.cmd == nil {
+		return
+	}
+	err := c.cmd.Wait()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		fmt.Printf("waiting for the program failed: %v\n", err)
+	}
+	c.mu.Lock()
+	c.exitState = c.cmd.ProcessState
+	c.mu.Unlock()
+	if c.Hold && c.exitState != nil {
+		c.handleOps(parser.New().Parse([]byte(fmt.Sprintf("\r\n[process exited: %s]", c.exitState))))
+		c.requestRender()
+	}
+}
+
+// Exited returns true when the program exited, the PTY can't be written into anymore
+func (c *Controller) Exited() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.exitState != nil
+}
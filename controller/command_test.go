@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runUntilExit starts the command in the controller and waits until it exits
+func runUntilExit(t *testing.T, c *Controller, cmd Command) {
+	t.Helper()
+	if err := c.Start(cmd, 200, 5); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		select {
+		case <-c.Render():
+		case <-c.Done:
+			return
+		case <-time.After(5 * time.Second):
+			t.Fatal("the command should have exited")
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	t.Run("runs the command with arguments in the directory", func(t *testing.T) {
+		dir, err := filepath.EvalSymlinks(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := &Controller{}
+		runUntilExit(t, c, Command{Path: "/bin/sh", Args: []string{"-c", "pwd"}, Dir: dir})
+		if screen := c.buffer.String(); !strings.Contains(screen, dir) {
+			t.Fatalf("the screen should contain the working directory %q, but it is:\n%s", dir, screen)
+		}
+	})
+
+	t.Run("holds the screen with the exit status", func(t *testing.T) {
+		c := &Controller{Hold: true}
+		runUntilExit(t, c, Command{Path: "/bin/sh", Args: []string{"-c", "echo bye; exit 3"}})
+		screen := c.buffer.String()
+		if !strings.Contains(screen, "bye") || !strings.Contains(screen, "[process exited: exit status 3]") {
+			t.Fatalf("the screen should contain the output and the exit status, but it is:\n%s", screen)
+		}
+		if !c.Exited() {
+			t.Fatal("the controller should know that the program exited")
+		}
+		// typing after the program exited doesn't do anything
+		c.KeyPressed("A", 0)
+	})
+
+	t.Run("renders the exit status", func(t *testing.T) {
+		c := &Controller{Hold: true}
+		// the program doesn't write anything, so only the exit status needs a render
+		if err := c.Start(Command{Path: "/bin/sh", Args: []string{"-c", "exit 3"}}, 200, 5); err != nil {
+			t.Fatal(err)
+		}
+		<-c.Done
+		select {
+		case _, ok := <-c.Render():
+			if !ok {
+				t.Fatal("the exit status should have been rendered")
+			}
+		case <-time.After(finalRenderTimeout / 2):
+			t.Fatal("the exit status should have been rendered")
+		}
+	})
+
+	t.Run("fails when the command doesn't exist", func(t *testing.T) {
+		c := &Controller{}
+		if err := c.Start(Command{Path: filepath.Join(os.TempDir(), "gritty-missing-command")}, 40, 5); err == nil {
+			t.Fatal("starting a missing command should fail")
+		}
+	})
+}
@@ -7,7 +7,9 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"strings"
 	"sync"
+	"time"
 
 	"gioui.org/io/key"
 	"github.com/creack/pty"
@@ -15,41 +17,146 @@ import (
 	"github.com/viktomas/gritty/parser"
 )
 
+// minFrameInterval caps the render signals at 120 frames per second,
+// the GUI can't show more and the program output gets handled faster when the GUI doesn't wake up for every change
+const minFrameInterval = time.Second / 120
+
+// ptyReadSize is how many bytes we read from the PTY at once, all operations from one read get rendered in one frame
+const ptyReadSize = 32 * 1024
+
+// finalRenderTimeout is how long the last render signal waits for the GUI after the program exits,
+// the GUI that doesn't hold the screen stops reading the signals
+const finalRenderTimeout = time.Second
+
 type Controller struct {
 	buffer *buffer.Buffer
 	ptmx   *os.File
 	mu     sync.RWMutex
 	render chan struct{}
-	in     chan []byte
-	Done   chan struct{}
+	// dirty holds one pending render request, all changes made before the GUI picks it up get rendered in one frame
+	dirty chan struct{}
+	in    chan []byte
+	Done  chan struct{}
+	// title is the window title set by the program running in the terminal
+	title string
+	// cwd is the working directory reported by the shell with OSC 7
+	cwd string
+	// foregroundCwd caches the working directory of the foreground process, it's looked up
+	// again after the program writes something because that's when the foreground process can change
+	foregroundCwd *string
+	// AllowClipboardRead lets programs read the clipboard with OSC 52.
+	// It's disabled by default because any program (even on a remote machine) could read secrets from the clipboard.
+	AllowClipboardRead bool
+	// clipboard is the text that the program copied with OSC 52 and the GUI hasn't put into the clipboard yet
+	clipboard *string
+	// clipboardRead is the selection (Pc) of the OSC 52 read request that the GUI hasn't answered yet, empty means no request
+	clipboardRead string
+	// Bell configures what happens when the program rings the bell
+	Bell Bell
+	// bellRung is true if the program rang the bell and the GUI hasn't found out yet
+	bellRung bool
+	// lastBellCommand is when the Bell.Command ran the last time
+	lastBellCommand time.Time
+	// Notifier shows the desktop notifications requested by the program, nil ignores the notifications
+	Notifier Notifier
+	// synchronized is a copy of the buffer from the start of the synchronized update, GUI renders it until the update ends
+	synchronized *buffer.Buffer
+	// syncTimer ends the synchronized update if it takes too long
+	syncTimer *time.Timer
+	// syncGeneration identifies the synchronized update so the timer doesn't end a newer update
+	syncGeneration int
+	// options are the user settings applied with SetOptions, nil means the defaults
+	options *Options
+	// Hold keeps the screen after the program exits and shows the exit status on it
+	Hold bool
+	// cmd is the program running in the PTY
+	cmd *exec.Cmd
+	// exitState is the state of the program after it exited, nil while it runs
+	exitState *os.ProcessState
 }
 
 func (c *Controller) Started() bool {
 	return c.buffer != nil
 }
 
-func (c *Controller) Start(shell string, cols, rows int) error {
-	cmd := exec.Command(shell)
-	cmd.Env = append(cmd.Env, "TERM=xterm-256color")
+// Start runs the command in a new PTY with the size cols x rows
+func (c *Controller) Start(command Command, cols, rows int) error {
+	cmd := command.exec()
+	c.mu.Lock()
 	c.buffer = buffer.New(cols, rows)
+	c.applyOptions()
+	c.mu.Unlock()
 	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)})
 	if err != nil {
 		return fmt.Errorf("failed to start PTY %w", err)
 	}
-	render := make(chan struct{})
-	c.render = render
 	c.ptmx = ptmx
+	c.cmd = cmd
+	c.run(ptmx)
+	return nil
+}
+
+// run handles the program output from the PTY and sends render signals until the program exits
+func (c *Controller) run(ptmx io.ReadCloser) {
+	c.render = make(chan struct{})
+	c.dirty = make(chan struct{}, 1)
 	c.Done = make(chan struct{})
-	ops := processPTY(c.ptmx)
-	go func() {
-		for op := range ops {
-			c.handleOp(op)
-			c.render <- struct{}{}
+	go c.processOps(processPTY(ptmx))
+	go c.renderLoop()
+}
+
+// processOps handles every batch of operations under one lock and then asks for rendering
+func (c *Controller) processOps(batches <-chan []parser.Operation) {
+	for ops := range batches {
+		c.handleOps(ops)
+		if !c.renderHeld() {
+			c.requestRender()
 		}
-		close(c.Done)
-	}()
-	return nil
+	}
+	c.waitForExit()
+	close(c.Done)
+}
 
+// requestRender marks the screen as dirty, it never blocks, so the PTY reader doesn't wait for the GUI
+func (c *Controller) requestRender() {
+	select {
+	case c.dirty <- struct{}{}:
+	default: // render is already pending, it will include the latest changes
+	}
+}
+
+// renderLoop sends at most one render signal per frame, no matter how many times the screen changed
+// it closes the Render channel after the last render signal
+func (c *Controller) renderLoop() {
+	defer close(c.render)
+	for {
+		select {
+		case <-c.dirty:
+		case <-c.Done:
+			// select picks randomly when both are ready, the changes made right before the exit still need their frame
+			select {
+			case <-c.dirty:
+				c.finalRender()
+			default:
+			}
+			return
+		}
+		select {
+		case c.render <- struct{}{}:
+		case <-c.Done:
+			c.finalRender()
+			return
+		}
+		time.Sleep(minFrameInterval)
+	}
+}
+
+// finalRender sends the render signal for the changes made before the program exited
+func (c *Controller) finalRender() {
+	select {
+	case c.render <- struct{}{}:
+	case <-time.After(finalRenderTimeout):
+	}
 }
 
 func (c *Controller) Resize(cols, rows int) {
@@ -62,27 +169,164 @@ func (c *Controller) Resize(cols, rows int) {
 
 func (c *Controller) KeyPressed(name string, mod key.Modifiers) {
 	logDebug("key pressed %v, modifiers: %v\n", name, mod)
-	_, err := c.ptmx.Write(keyToBytes(name, mod))
+	c.sendKey(name, mod, key.Press)
+}
+
+// KeyReleased sends the key release to programs that asked for the key event types (kitty keyboard protocol)
+func (c *Controller) KeyReleased(name string, mod key.Modifiers) {
+	c.sendKey(name, mod, key.Release)
+}
+
+func (c *Controller) sendKey(name string, mod key.Modifiers, state key.State) {
+	if c.Exited() {
+		return
+	}
+	c.mu.Lock()
+	flags := c.buffer.KeyboardFlags()
+	if state == key.Press {
+		// typing brings the view back from the scrollback
+		c.buffer.ResetView()
+	}
+	c.mu.Unlock()
+	encoded := encodeKey(name, mod, state, flags)
+	if encoded == nil {
+		return
+	}
+	_, err := c.ptmx.Write(encoded)
+	// the PTY gets closed when the program exits
+	if errors.Is(err, os.ErrClosed) {
+		return
+	}
 	if err != nil {
 		log.Fatalf("writing key into PTY failed with error: %v", err)
 		return
 	}
 }
 
+// SetFocused tells the program that the window gained or lost focus, if the program enabled the focus reporting (mode 1004)
+func (c *Controller) SetFocused(focused bool) {
+	if !c.Started() || c.Exited() {
+		return
+	}
+	c.mu.RLock()
+	reporting := c.buffer.FocusReporting()
+	c.mu.RUnlock()
+	if !reporting {
+		return
+	}
+	report := "\x1b[O"
+	if focused {
+		report = "\x1b[I"
+	}
+	if _, err := c.ptmx.Write([]byte(report)); err != nil && !errors.Is(err, os.ErrClosed) {
+		log.Printf("Error when writing focus event to PTY: %v", err)
+	}
+}
+
 func (c *Controller) Runes() []buffer.BrushedRune {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.buffer.Runes()
+	return c.screen().Runes()
+}
+
+// Size returns the number of rows and columns of the terminal screen
+func (c *Controller) Size() buffer.BufferSize {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.screen().Size()
+}
+
+// Cursor returns the cursor position in the view, its style and whether the cursor should be visible.
+// The cursor isn't visible when the program hides it or when the view is scrolled up too far.
+func (c *Controller) Cursor() (buffer.Cursor, buffer.CursorStyle, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	screen := c.screen()
+	cursor, inView := screen.ViewCursor()
+	return cursor, screen.CursorStyle(), screen.CursorVisible() && inView
+}
+
+// TakeDamage returns the rows of the view that changed since the last call, the caller should redraw them
+func (c *Controller) TakeDamage() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.screen().TakeDamage()
+}
+
+// LineAttrs returns the size attribute of every line on the screen
+func (c *Controller) LineAttrs() []buffer.LineAttr {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.screen().LineAttrs()
+}
+
+// Link returns the hyperlink referenced by buffer.BrushedRune.Link
+func (c *Controller) Link(ref int) (buffer.Link, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.screen().Link(ref)
+}
+
+// ScrollView moves the view n lines up into the scrollback, negative n moves it down
+func (c *Controller) ScrollView(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buffer.ScrollView(n)
+}
+
+// LineMarks returns the shell integration marks of every line in the view
+func (c *Controller) LineMarks() []buffer.LineMarks {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.screen().LineMarks()
+}
+
+// PreviousPrompt scrolls the view to the previous shell prompt
+func (c *Controller) PreviousPrompt() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buffer.PreviousPrompt()
+}
+
+// NextPrompt scrolls the view to the next shell prompt
+func (c *Controller) NextPrompt() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buffer.NextPrompt()
+}
+
+// LastCommandOutput returns the output of the last command, it needs the shell integration (OSC 133)
+func (c *Controller) LastCommandOutput() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.buffer.LastCommandOutput()
+}
+
+// Palette returns the palette that translates the character colors to RGB
+func (c *Controller) Palette() buffer.Palette {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.screen().Palette()
+}
+
+// Title returns the window title requested by the program running in the terminal.
+// It returns an empty string if no program set the title.
+func (c *Controller) Title() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.title
 }
 
 // Render returns a channel that will get signal every time we need to
-// redraw the terminal GUI
+// redraw the terminal GUI, the channel is closed after the program exits
 func (c *Controller) Render() <-chan struct{} {
 	return c.render
 }
 
 func (c *Controller) executeOp(r rune) {
 	switch r {
+	case asciiBEL:
+		c.bell()
 	case asciiHT:
 		c.buffer.Tab()
 	case asciiBS:
@@ -91,42 +335,128 @@ func (c *Controller) executeOp(r rune) {
 		c.buffer.CR()
 	case asciiLF:
 		c.buffer.LF()
+	case asciiSO: // LS1 - Locking Shift G1
+		c.buffer.LockingShift(1)
+	case asciiSI: // LS0 - Locking Shift G0
+		c.buffer.LockingShift(0)
+	case 0x88: // HTS - Horizontal Tab Set, coming from ESC H
+		c.buffer.SetTabStop()
 	case 0x8d: // this is coming from ESC M https://vt100.net/docs/vt100-ug/chapter3.html
 		c.buffer.ReverseIndex()
+	case 0x8e: // SS2 - Single Shift G2, coming from ESC N
+		c.buffer.SingleShift(2)
+	case 0x8f: // SS3 - Single Shift G3, coming from ESC O
+		c.buffer.SingleShift(3)
+	case 0x9c: // ST - String Terminator, coming from ESC \, the parser already ended the string
 	default:
 		fmt.Printf("Unknown control character 0x%x\n", r)
 	}
 }
 
 func (c *Controller) handleOp(op parser.Operation) {
+	c.handleOps([]parser.Operation{op})
+}
+
+// handleOps handles all operations from one PTY read under one lock
+func (c *Controller) handleOps(ops []parser.Operation) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.foregroundCwd = nil
+	for _, op := range ops {
+		c.dispatchOp(op)
+	}
+}
 
+func (c *Controller) dispatchOp(op parser.Operation) {
 	logDebug("%v\n", op)
 	switch op.T {
 	case parser.OpExecute:
 		c.executeOp(op.R)
 	case parser.OpPrint:
-		c.buffer.WriteRune(op.R)
+		c.buffer.WriteRune(c.buffer.TranslateRune(op.R))
 	case parser.OpCSI:
 		translateCSI(op, c.buffer, c.ptmx)
 	case parser.OpOSC:
-		fmt.Println("unhandled OSC instruction: ", op)
+		c.translateOSC(op)
+	case parser.OpDCS:
+		c.translateDCS(op)
 	case parser.OpESC:
-		if op.R >= '@' && op.R <= '_' && op.Intermediate == "" {
-			c.executeOp(op.R + 0x40)
-		} else {
-			fmt.Println("Unknown ESC op: ", op)
-		}
+		c.handleESC(op)
 	default:
 		fmt.Printf("unhandled op type %v\n", op)
 	}
+	c.updateSynchronizedOutput()
+}
+
+// handleESC enacts the escape sequences (ESC followed by intermediate and final characters)
+func (c *Controller) handleESC(op parser.Operation) {
+	switch {
+	case op.R >= '@' && op.R <= '_' && op.Intermediate == "":
+		c.executeOp(op.R + 0x40)
+	case op.R == 'c' && op.Intermediate == "":
+		c.reset()
+	case op.R == '7' && op.Intermediate == "": // DECSC - Save Cursor
+		c.buffer.SaveCursor()
+	case op.R == '8' && op.Intermediate == "": // DECRC - Restore Cursor
+		c.buffer.RestoreCursor()
+	case op.R == 'n' && op.Intermediate == "": // LS2 - Locking Shift G2
+		c.buffer.LockingShift(2)
+	case op.R == 'o' && op.Intermediate == "": // LS3 - Locking Shift G3
+		c.buffer.LockingShift(3)
+	case len(op.Intermediate) == 1 && strings.Contains("()*+", op.Intermediate):
+		c.designateCharset(op)
+	case op.Intermediate == "#":
+		c.lineAttributeOp(op)
+	default:
+		fmt.Println("Unknown ESC op: ", op)
+	}
+}
+
+// designateCharset handles the SCS (Select Character Set) sequence ESC I F
+// where I selects the slot ( is G0, ) is G1, * is G2 and + is G3, and F selects the charset
+// https://vt100.net/docs/vt510-rm/SCS.html
+func (c *Controller) designateCharset(op parser.Operation) {
+	g := strings.Index("()*+", op.Intermediate)
+	switch op.R {
+	case 'B':
+		c.buffer.DesignateCharset(g, buffer.CharsetASCII)
+	case 'A':
+		c.buffer.DesignateCharset(g, buffer.CharsetUK)
+	case '0':
+		c.buffer.DesignateCharset(g, buffer.CharsetDECSpecialGraphics)
+	default:
+		fmt.Println("Unknown charset: ", op)
+	}
+}
+
+// lineAttributeOp handles the ESC # sequences that change the line size or fill the screen with a test pattern
+func (c *Controller) lineAttributeOp(op parser.Operation) {
+	switch op.R {
+	case '3': // DECDHL - Double-Height Line, top half
+		c.buffer.SetLineAttr(buffer.LineDoubleHeightTop)
+	case '4': // DECDHL - Double-Height Line, bottom half
+		c.buffer.SetLineAttr(buffer.LineDoubleHeightBottom)
+	case '5': // DECSWL - Single-Width Line
+		c.buffer.SetLineAttr(buffer.LineSingleWidth)
+	case '6': // DECDWL - Double-Width Line
+		c.buffer.SetLineAttr(buffer.LineDoubleWidth)
+	case '8': // DECALN - Screen Alignment Pattern
+		c.buffer.ScreenAlignment()
+	default:
+		fmt.Println("Unknown ESC # op: ", op)
+	}
+}
 
+// reset puts the terminal into its initial state
+// RIS - Reset to Initial State https://vt100.net/docs/vt510-rm/RIS.html
+func (c *Controller) reset() {
+	c.buffer.Reset()
+	c.title = ""
 }
 
-func processPTY(ptmx *os.File) <-chan parser.Operation {
-	out := make(chan parser.Operation)
-	buf := make([]byte, 1024)
+func processPTY(ptmx io.ReadCloser) <-chan []parser.Operation {
+	out := make(chan []parser.Operation)
+	buf := make([]byte, ptyReadSize)
 	parser := parser.New()
 	go func() {
 		defer func() {
@@ -142,8 +472,8 @@ func processPTY(ptmx *os.File) <-chan parser.Operation {
 				}
 				return
 			}
-			for _, op := range parser.Parse(buf[:n]) {
-				out <- op
+			if ops := parser.Parse(buf[:n]); len(ops) > 0 {
+				out <- ops
 			}
 		}
 	}()
@@ -1,6 +1,9 @@
 package controller
 
 import (
+	"io"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/viktomas/gritty/buffer"
@@ -18,3 +21,59 @@ func FuzzController(f *testing.F) {
 		}
 	})
 }
+
+// handleInput parses the input and handles all resulting operations
+func handleInput(c *Controller, in string) {
+	for _, op := range parser.New().Parse([]byte(in)) {
+		c.handleOp(op)
+	}
+}
+
+// makePipeController returns a controller that writes its replies into the returned reader
+func makePipeController(t *testing.T) (*Controller, *os.File) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		r.Close()
+		w.Close()
+	})
+	return &Controller{buffer: buffer.New(10, 10), ptmx: w}, r
+}
+
+// readReply closes the controller PTY and returns everything the controller wrote into it
+func readReply(t *testing.T, c *Controller, r io.Reader) string {
+	t.Helper()
+	c.ptmx.Close()
+	reply, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(reply)
+}
+
+func TestFocusReporting(t *testing.T) {
+	c, r := makePipeController(t)
+	// the program didn't ask for the focus events yet
+	c.SetFocused(true)
+	handleInput(c, "\x1b[?1004h")
+	c.SetFocused(false)
+	c.SetFocused(true)
+	handleInput(c, "\x1b[?1004$p\x1b[?1004l")
+	c.SetFocused(false)
+	expected := "\x1b[O\x1b[I\x1b[?1004;1$y"
+	if reply := readReply(t, c, r); reply != expected {
+		t.Fatalf("the reply should have been %q, but was %q", expected, reply)
+	}
+}
+
+func TestSGRResetKeepsProtection(t *testing.T) {
+	c := &Controller{buffer: buffer.New(10, 2)}
+	handleInput(c, "\x1b[1\"qab\x1b[0mc\x1b[0\"qd\x1b[?2J")
+	line := strings.SplitN(c.buffer.String(), "\n", 2)[0]
+	if line != "abc       " {
+		t.Fatalf("the selective erase should keep the protected characters written before and after SGR 0, got %q", line)
+	}
+}
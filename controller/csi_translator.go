@@ -28,8 +28,101 @@ func translateCSI(op parser.Operation, b *buffer.Buffer, pty io.Writer) {
 				if err != nil {
 					log.Printf("Error when writing device information to PTY: %v", err)
 				}
+			} else {
+				log.Println("unknown device attributes request: ", op)
 			}
 
+		case 'p':
+			switch op.Intermediate {
+			// DECSTR - Soft Terminal Reset https://vt100.net/docs/vt510-rm/DECSTR.html
+			case "!":
+				b.SoftReset()
+			// DECRQM - Request Mode https://vt100.net/docs/vt510-rm/DECRQM.html
+			case "$", "?$":
+				reportMode(op, b, pty)
+			default:
+				log.Println("unknown CSI p sequence: ", op)
+			}
+		case 'q':
+			switch op.Intermediate {
+			// DECSCA - Select Character Protection Attribute https://vt100.net/docs/vt510-rm/DECSCA.html
+			case "\"":
+				br := b.Brush()
+				br.Protected = op.Param(0, 0) == 1
+				b.SetBrush(br)
+			// DECSCUSR - Set Cursor Style https://vt100.net/docs/vt510-rm/DECSCUSR.html
+			case " ":
+				ps := op.Param(0, 0)
+				switch ps {
+				case 0:
+					b.ResetCursorStyle()
+				case 1, 2, 3, 4, 5, 6:
+					// 1 blinking block, 2 steady block, 3 blinking underline, 4 steady underline, 5 blinking bar, 6 steady bar
+					shapes := []buffer.CursorShape{buffer.CursorBlock, buffer.CursorUnderline, buffer.CursorBar}
+					b.SetCursorStyle(buffer.CursorStyle{Shape: shapes[(ps-1)/2], Blinking: ps%2 == 1})
+				default:
+					log.Println("unknown DECSCUSR parameter: ", op)
+				}
+			// XTVERSION - Report xterm name and version
+			case ">":
+				reportVersion(pty)
+			default:
+				log.Println("unknown CSI q sequence: ", op)
+			}
+		case 'u':
+			keyboardProtocol(op, b, pty)
+		case 'v':
+			// DECCRA - Copy Rectangular Area https://vt100.net/docs/vt510-rm/DECCRA.html
+			// Pts;Pls;Pbs;Prs;Pps;Ptd;Pld;Ppd, we ignore the pages (Pps, Ppd) because we have only one
+			if op.Intermediate == "$" {
+				src := rectParam(op, 0, b)
+				top, left := op.Param(5, 1)-1, op.Param(6, 1)-1
+				dst := b.RectFromOrigin(top, left, top+src.Bottom-src.Top, left+src.Right-src.Left)
+				b.CopyRect(src, dst)
+			}
+		case 'x':
+			switch op.Intermediate {
+			// DECFRA - Fill Rectangular Area https://vt100.net/docs/vt510-rm/DECFRA.html
+			case "$":
+				ch := op.Param(0, 0)
+				// only printable characters can be used for filling
+				if (ch >= 32 && ch <= 126) || (ch >= 160 && ch <= 255) {
+					b.FillRect(rectParam(op, 1, b), rune(ch))
+				}
+			// DECSACE - Select Attribute Change Extent https://vt100.net/docs/vt510-rm/DECSACE.html
+			case "*":
+				b.SetRectAttributeChangeExtent(op.Param(0, 0) == 2)
+			}
+		case 'z':
+			// DECERA - Erase Rectangular Area https://vt100.net/docs/vt510-rm/DECERA.html
+			if op.Intermediate == "$" {
+				b.EraseRect(rectParam(op, 0, b))
+			}
+		case '{':
+			// DECSERA - Selective Erase Rectangular Area https://vt100.net/docs/vt510-rm/DECSERA.html
+			if op.Intermediate == "$" {
+				b.SelectiveEraseRect(rectParam(op, 0, b))
+			}
+		case 'J':
+			// DECSED - Selective Erase in Display https://vt100.net/docs/vt510-rm/DECSED.html
+			if op.Intermediate == "?" {
+				selectiveErase(op, b, true)
+			}
+		case 'K':
+			// DECSEL - Selective Erase in Line https://vt100.net/docs/vt510-rm/DECSEL.html
+			if op.Intermediate == "?" {
+				selectiveErase(op, b, false)
+			}
+		case 'r':
+			// DECCARA - Change Attributes in Rectangular Area https://vt100.net/docs/vt510-rm/DECCARA.html
+			if op.Intermediate == "$" {
+				b.ChangeRectAttributes(rectParam(op, 0, b), changeAttributes(op.Params[min(4, len(op.Params)):]))
+			}
+		case 't':
+			// DECRARA - Reverse Attributes in Rectangular Area https://vt100.net/docs/vt510-rm/DECRARA.html
+			if op.Intermediate == "$" {
+				b.ChangeRectAttributes(rectParam(op, 0, b), reverseAttributes(op.Params[min(4, len(op.Params)):]))
+			}
 		case 'h':
 			// DEC Private Mode Set (DECSET).
 			// source https://invisible-island.net/xterm/ctlseqs/ctlseqs.html
@@ -38,13 +131,27 @@ func translateCSI(op parser.Operation, b *buffer.Buffer, pty io.Writer) {
 				// Origin Mode (DECOM), VT100.
 				case 6:
 					b.SetOriginMode(true)
+				// Show cursor (DECTCEM), VT220.
+				case 25:
+					b.SetCursorVisible(true)
+				// Enable left and right margin mode (DECLRMM), VT420 and up.
+				case 69:
+					b.SetLeftRightMarginMode(true)
+				// Send FocusIn/FocusOut events, xterm.
+				case 1004:
+					b.SetFocusReporting(true)
 				// Save cursor as in DECSC, After saving the cursor, switch to the Alternate Screen Buffer,
 				case 1049:
 					b.SaveCursor()
 					b.SwitchToAlternateBuffer()
+				// Begin Synchronized Update, the screen isn't rendered until the update ends
+				case 2026:
+					b.SetSynchronizedOutput(true)
 				default:
 					log.Println("unknown DEC Private mode set parameter: ", op)
 				}
+			} else {
+				log.Println("unknown mode set: ", op)
 			}
 		case 'l':
 			if op.Intermediate == "?" {
@@ -52,13 +159,27 @@ func translateCSI(op parser.Operation, b *buffer.Buffer, pty io.Writer) {
 				// Normal Cursor Mode (DECOM)
 				case 6:
 					b.SetOriginMode(false)
+				// Hide cursor (DECTCEM), VT220.
+				case 25:
+					b.SetCursorVisible(false)
+				// Disable left and right margin mode (DECLRMM), VT420 and up.
+				case 69:
+					b.SetLeftRightMarginMode(false)
+				// Don't send FocusIn/FocusOut events, xterm.
+				case 1004:
+					b.SetFocusReporting(false)
 				// Use Normal Screen Buffer and restore cursor as in DECRC
 				case 1049:
 					b.SwitchToPrimaryBuffer()
 					b.RestoreCursor()
+				// End Synchronized Update
+				case 2026:
+					b.SetSynchronizedOutput(false)
 				default:
-					log.Println("unknown DEC Private mode set parameter: ", op)
+					log.Println("unknown DEC Private mode reset parameter: ", op)
 				}
+			} else {
+				log.Println("unknown mode reset: ", op)
 			}
 		default:
 			fmt.Printf("unknown CSI sequence with intermediate char %v\n", op)
@@ -122,6 +243,12 @@ func translateCSI(op parser.Operation, b *buffer.Buffer, pty io.Writer) {
 		b.SetCursor(op.Param(1, 1)-1, op.Param(0, 1)-1)
 	case 'P': // DCH - Delete character - https://vt100.net/docs/vt510-rm/DCH.html
 		b.DeleteCharacter(op.Param(0, 1))
+	case '@': // ICH - Insert Character - https://vt100.net/docs/vt510-rm/ICH.html
+		b.InsertCharacter(op.Param(0, 1))
+	case 'S': // SU - Scroll Up - https://vt100.net/docs/vt510-rm/SU.html
+		b.ScrollUp(op.Param(0, 1))
+	case 'T': // SD - Scroll Down - https://vt100.net/docs/vt510-rm/SD.html
+		b.ScrollDown(op.Param(0, 1))
 	case 'X': //ECH—Erase Character https://vt100.net/docs/vt510-rm/ECH.html
 		b.ClearCurrentLine(b.Cursor().X, b.Cursor().X+op.Param(0, 1))
 	case 'r':
@@ -133,169 +260,224 @@ func translateCSI(op parser.Operation, b *buffer.Buffer, pty io.Writer) {
 		// but the scroll area takes the index of the first line (starts with 0)
 		// and index (starting from zero) of the last line + 1
 		b.SetScrollArea(start-1, end)
+	case 'h': // SM - Set Mode https://vt100.net/docs/vt510-rm/SM.html
+		setANSIModes(op, b, true)
+	case 'l': // RM - Reset Mode https://vt100.net/docs/vt510-rm/RM.html
+		setANSIModes(op, b, false)
+	case 'g': // TBC - Tab Clear https://vt100.net/docs/vt510-rm/TBC.html
+		switch op.Param(0, 0) {
+		case 0:
+			b.ClearTabStop()
+		case 3:
+			b.ClearAllTabStops()
+		default:
+			log.Println("unknown CSI g parameter: ", op.Params[0])
+		}
 	case 's':
-		b.SaveCursor()
+		// DECSLRM - Set Left and Right Margins https://vt100.net/docs/vt510-rm/DECSLRM.html
+		// when the left and right margin mode is disabled, CSI s saves the cursor
+		if b.LeftRightMarginMode() {
+			// same as with DECSTBM, the parameters start with 1 so the right margin is already the index of the last column + 1
+			b.SetLeftRightMargins(op.Param(0, 1)-1, op.Param(1, b.Size().Cols))
+		} else {
+			b.SaveCursor()
+		}
 	case 'u':
 		b.RestoreCursor()
 	case 'c':
 		// inspired by https://github.com/liamg/darktile/blob/159932ff3ecdc9f7d30ac026480587b84edb895b/internal/app/darktile/termutil/csi.go#L305
-		// we are VT100
-		// for DA1 we'll respond ?1;2
-		_, err := pty.Write([]byte("\x1b[?1;2c"))
+		_, err := pty.Write([]byte(primaryDeviceAttributes))
 		if err != nil {
 			log.Printf("Error when writing device information to PTY: %v", err)
 		}
-		// SGR https://vt100.net/docs/vt510-rm/SGR.html
+	// SGR https://vt100.net/docs/vt510-rm/SGR.html
 	case 'm':
-		ps := op.Param(0, 0)
-		// 4bit color
-		if ps >= 30 && ps <= 37 {
-			color := get3bitNormalColor(uint8(ps - 30))
-			br := b.Brush()
-			br.FG = color
-			b.SetBrush(br)
-			return
+		b.SetBrush(applySGR(op.Params, b.Brush()))
+	default:
+		log.Printf("Unknown CSI instruction %v", op)
+	}
+}
+
+// gritty identifies as VT100 (conformance level 1), DA1 and DECSCL have to agree
+const (
+	// primaryDeviceAttributes is the DA1 reply of VT100 with Advanced Video Option https://vt100.net/docs/vt510-rm/DA1.html
+	primaryDeviceAttributes = "\x1b[?1;2c"
+	// conformanceLevelStatus is the DECRQSS reply for DECSCL, level 1 doesn't have the 7-bit/8-bit controls parameter
+	conformanceLevelStatus = "61\"p"
+)
+
+// setANSIModes sets or resets all ANSI modes in the parameters
+func setANSIModes(op parser.Operation, b *buffer.Buffer, set bool) {
+	for _, mode := range op.Params {
+		switch mode {
+		// IRM - Insert/Replace Mode
+		case 4:
+			b.SetInsertMode(set)
+		default:
+			log.Println("unknown ANSI mode: ", op)
 		}
-		if ps >= 90 && ps <= 97 {
-			color := get3bitBrightColor(uint8(ps - 90))
-			br := b.Brush()
-			br.FG = color
-			b.SetBrush(br)
-			return
+	}
+}
+
+// selectiveErase erases the unprotected characters from the cursor to the end (Ps=0), from the start to the cursor (Ps=1)
+// or everything (Ps=2) in the line, or the whole display if display is true
+func selectiveErase(op parser.Operation, b *buffer.Buffer, display bool) {
+	cur, size := b.Cursor(), b.Size()
+	line := func(left, right int) buffer.Rect {
+		return buffer.Rect{Top: cur.Y, Left: left, Bottom: cur.Y + 1, Right: right}
+	}
+	switch op.Param(0, 0) {
+	case 0:
+		b.SelectiveEraseRect(line(cur.X, size.Cols))
+		if display {
+			b.SelectiveEraseRect(buffer.Rect{Top: cur.Y + 1, Left: 0, Bottom: size.Rows, Right: size.Cols})
 		}
-		if ps >= 40 && ps <= 47 {
-			color := get3bitNormalColor(uint8(ps - 40))
-			br := b.Brush()
-			br.BG = color
-			b.SetBrush(br)
-			return
+	case 1:
+		b.SelectiveEraseRect(line(0, cur.X+1))
+		if display {
+			b.SelectiveEraseRect(buffer.Rect{Top: 0, Left: 0, Bottom: cur.Y, Right: size.Cols})
 		}
-		if ps >= 100 && ps <= 107 {
-			color := get3bitNormalColor(uint8(ps - 100))
-			br := b.Brush()
-			br.BG = color
-			b.SetBrush(br)
-			return
+	case 2:
+		if display {
+			b.SelectiveEraseRect(buffer.Rect{Top: 0, Left: 0, Bottom: size.Rows, Right: size.Cols})
+		} else {
+			b.SelectiveEraseRect(line(0, size.Cols))
 		}
-		switch ps {
-		case 0:
-			b.ResetBrush()
-		case 1:
-			br := b.Brush()
-			br.Bold = true
-			b.SetBrush(br)
-		case 7:
-			br := b.Brush()
-			br.Invert = true
-			b.SetBrush(br)
-		case 27:
-			br := b.Brush()
-			br.Invert = false
-			b.SetBrush(br)
-		case 38:
-			switch op.Param(1, 0) {
-			case 5:
-				color := get256Color(uint8(op.Param(2, 0)))
-				br := b.Brush()
-				br.FG = color
-				b.SetBrush(br)
-			case 2:
-				color := buffer.NewColor(uint8(op.Param(2, 0)), uint8(op.Param(3, 0)), uint8(op.Param(4, 0)))
-				br := b.Brush()
-				br.FG = color
-				b.SetBrush(br)
+	default:
+		log.Println("unknown selective erase parameter: ", op)
+	}
+}
 
-			default:
-				log.Printf("unknown SGR instruction %v\n", op)
-			}
+// rectParam reads the rectangle coordinates Pt;Pl;Pb;Pr starting with the i-th parameter.
+// The coordinates start with 1 and the bottom and right are inclusive,
+// which is the same as our 0-based indexes of the last row and column + 1.
+// Missing coordinates default to the whole screen.
+func rectParam(op parser.Operation, i int, b *buffer.Buffer) buffer.Rect {
+	return b.RectFromOrigin(
+		op.Param(i, 1)-1,
+		op.Param(i+1, 1)-1,
+		op.Param(i+2, b.Size().Rows),
+		op.Param(i+3, b.Size().Cols),
+	)
+}
 
-		case 39:
-			br := b.Brush()
-			br.FG = buffer.DefaultFG
-			b.SetBrush(br)
-		case 48:
-			switch op.Param(1, 0) {
+// changeAttributes returns a function that sets or resets the SGR attributes that are allowed in DECCARA
+// (0 - all attributes off, 1 bold, 4 underline, 5 blink, 7 negative image, and 22, 24, 25, 27 turn them off)
+func changeAttributes(params []int) func(buffer.Brush) buffer.Brush {
+	if len(params) == 0 {
+		params = []int{0}
+	}
+	return func(br buffer.Brush) buffer.Brush {
+		for _, ps := range params {
+			switch ps {
+			case 0:
+				br.Bold, br.Underline, br.Blink, br.Invert = false, false, false, false
+			case 1:
+				br.Bold = true
+			case 4:
+				br.Underline = true
 			case 5:
-				color := get256Color(uint8(op.Param(2, 0)))
-				br := b.Brush()
-				br.BG = color
-				b.SetBrush(br)
-			case 2:
-				color := buffer.NewColor(uint8(op.Param(2, 0)), uint8(op.Param(3, 0)), uint8(op.Param(4, 0)))
-				br := b.Brush()
-				br.BG = color
-				b.SetBrush(br)
-			default:
-				log.Printf("unknown SGR instruction %v\n", op)
+				br.Blink = true
+			case 7:
+				br.Invert = true
+			case 22:
+				br.Bold = false
+			case 24:
+				br.Underline = false
+			case 25:
+				br.Blink = false
+			case 27:
+				br.Invert = false
 			}
-		case 49:
-			br := b.Brush()
-			br.BG = buffer.DefaultBG
-			b.SetBrush(br)
-		default:
-			log.Printf("unknown SGR instruction %v\n", op)
 		}
-	default:
-		log.Printf("Unknown CSI instruction %v", op)
+		return br
 	}
 }
 
-// get3bitNormalColor returns a color based on the SGR 30-37
-// I used the VS Code color palette
-// more info here https://en.wikipedia.org/wiki/ANSI_escape_code#3-bit_and_4-bit
-func get3bitNormalColor(n uint8) buffer.Color {
-	if n > 7 {
-		log.Printf("get3BitNormalColor was invoked with incorrect number %d\n", n)
-		n = 0
+// reverseAttributes returns a function that toggles the SGR attributes that are allowed in DECRARA
+// (0 - all attributes, 1 bold, 4 underline, 5 blink, 7 negative image)
+func reverseAttributes(params []int) func(buffer.Brush) buffer.Brush {
+	if len(params) == 0 {
+		params = []int{0}
 	}
-	colors := []buffer.Color{
-		{R: 0, G: 0, B: 0},       // black
-		{R: 205, G: 49, B: 49},   // red
-		{R: 13, G: 188, B: 121},  // green
-		{R: 229, G: 229, B: 16},  // yellow
-		{R: 36, G: 114, B: 200},  // blue
-		{R: 188, G: 63, B: 188},  // magenta
-		{R: 17, G: 168, B: 205},  // cyan
-		{R: 229, G: 229, B: 229}, // white
+	return func(br buffer.Brush) buffer.Brush {
+		for _, ps := range params {
+			switch ps {
+			case 0:
+				br.Bold, br.Underline, br.Blink, br.Invert = !br.Bold, !br.Underline, !br.Blink, !br.Invert
+			case 1:
+				br.Bold = !br.Bold
+			case 4:
+				br.Underline = !br.Underline
+			case 5:
+				br.Blink = !br.Blink
+			case 7:
+				br.Invert = !br.Invert
+			}
+		}
+		return br
 	}
-	return colors[n]
 }
 
-// get3bitBrightColor returns a color based on the SGR 30-37 and 90-97 scale
-// I used the VS Code color palette
-// more info here https://en.wikipedia.org/wiki/ANSI_escape_code#3-bit_and_4-bit
-func get3bitBrightColor(n uint8) buffer.Color {
-	if n > 7 {
-		log.Printf("get3BitBrightColor was invoked with incorrect number %d\n", n)
-		n = 0
+// mode states reported by DECRPM (Report Mode)
+const (
+	modeNotRecognized    = 0
+	modeSet              = 1
+	modeReset            = 2
+	modePermanentlySet   = 3
+	modePermanentlyReset = 4
+)
+
+// reportMode answers DECRQM (CSI Ps $ p for ANSI modes and CSI ? Ps $ p for DEC private modes)
+// with DECRPM CSI [?] Ps ; Pm $ y, where Pm is the mode state
+// https://vt100.net/docs/vt510-rm/DECRPM.html
+func reportMode(op parser.Operation, b *buffer.Buffer, pty io.Writer) {
+	mode := op.Param(0, 0)
+	state := modeNotRecognized
+	private := op.Intermediate == "?$"
+	if private {
+		state = privateModeState(mode, b)
+	} else if mode == 4 {
+		state = modeReset
+		if b.InsertMode() {
+			state = modeSet
+		}
+	}
+	prefix := ""
+	if private {
+		prefix = "?"
 	}
-	colors := []buffer.Color{
-		{R: 102, G: 102, B: 102}, // bright black
-		{R: 241, G: 76, B: 76},   // bright red
-		{R: 35, G: 209, B: 139},  // bright green
-		{R: 245, G: 245, B: 67},  // bright yellow
-		{R: 59, G: 142, B: 234},  // bright blue
-		{R: 214, G: 112, B: 214}, // bright magenta
-		{R: 41, G: 184, B: 219},  // bright cyan
-		{R: 229, G: 229, B: 229}, // bright white
+	_, err := fmt.Fprintf(pty, "\x1b[%s%d;%d$y", prefix, mode, state)
+	if err != nil {
+		log.Printf("Error when writing mode report to PTY: %v", err)
 	}
-	return colors[n]
 }
 
-func get256Color(n uint8) buffer.Color {
-	switch {
-	case n < 8:
-		return get3bitNormalColor(n)
-	case n < 16:
-		return get3bitBrightColor(n - 8)
-	case n < 232:
-		// 6x6x6 color cube
-		n -= 16
-		levels := []uint8{0, 95, 135, 175, 215, 255}
-		return buffer.NewColor(levels[(n/36)%6], levels[(n/6)%6], levels[n%6])
+// privateModeState returns the state of the DEC private mode as reported by DECRPM
+func privateModeState(mode int, b *buffer.Buffer) int {
+	setOrReset := func(set bool) int {
+		if set {
+			return modeSet
+		}
+		return modeReset
+	}
+	switch mode {
+	case 6:
+		return setOrReset(b.OriginMode())
+	// DECAWM - Autowrap Mode, we always wrap
+	case 7:
+		return modePermanentlySet
+	case 25:
+		return setOrReset(b.CursorVisible())
+	case 69:
+		return setOrReset(b.LeftRightMarginMode())
+	case 1004:
+		return setOrReset(b.FocusReporting())
+	case 1049:
+		return setOrReset(b.AlternateScreen())
+	case 2026:
+		return setOrReset(b.SynchronizedOutput())
 	default:
-		// grayscale
-		level := 8 + (n-232)*10
-		return buffer.NewColor(level, level, level)
+		return modeNotRecognized
 	}
 }
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// workingDirectoryOSC handles OSC 7 ; file://host/path, the shell reports its working directory
+// we only record directories on this machine, a shell on a remote machine (ssh) reports paths that don't exist here
+// https://gitlab.freedesktop.org/terminal-wg/specifications/-/merge_requests/7
+func (c *Controller) workingDirectoryOSC(pt string) {
+	u, err := url.Parse(pt)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		fmt.Println("unhandled OSC 7 working directory: ", pt)
+		return
+	}
+	if !isLocalHost(u.Host) {
+		c.cwd = ""
+		return
+	}
+	c.cwd = u.Path
+}
+
+func isLocalHost(host string) bool {
+	if host == "" || host == "localhost" {
+		return true
+	}
+	hostname, err := os.Hostname()
+	return err == nil && hostname == host
+}
+
+// WorkingDirectory returns the working directory that the shell reported with OSC 7.
+// If the shell didn't report it, it returns the working directory of the foreground process (only on Linux)
+// It returns an empty string if the directory is unknown.
+func (c *Controller) WorkingDirectory() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cwd != "" || c.ptmx == nil {
+		return c.cwd
+	}
+	if c.foregroundCwd == nil {
+		cwd := foregroundWorkingDirectory(c.ptmx)
+		c.foregroundCwd = &cwd
+	}
+	return *c.foregroundCwd
+}
@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// foregroundWorkingDirectory returns the working directory of the PTY foreground process group leader
+func foregroundWorkingDirectory(ptmx *os.File) string {
+	conn, err := ptmx.SyscallConn()
+	if err != nil {
+		return ""
+	}
+	var pgrp int32
+	var errno syscall.Errno
+	// we use the raw connection because ptmx.Fd() would switch the PTY into blocking mode
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgrp)))
+	})
+	if err != nil || errno != 0 {
+		return ""
+	}
+	cwd, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", pgrp))
+	if err != nil {
+		return ""
+	}
+	return cwd
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package controller
+
+import "os"
+
+// foregroundWorkingDirectory is only implemented on Linux
+func foregroundWorkingDirectory(ptmx *os.File) string {
+	return ""
+}
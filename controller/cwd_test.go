@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/creack/pty"
+	"github.com/viktomas/gritty/buffer"
+)
+
+func TestWorkingDirectory(t *testing.T) {
+	t.Run("records the directory reported by the shell", func(t *testing.T) {
+		c := &Controller{buffer: buffer.New(10, 10)}
+		handleInput(c, "\x1b]7;file://localhost/tmp/a%20b\x1b\\")
+		if cwd := c.WorkingDirectory(); cwd != "/tmp/a b" {
+			t.Fatalf("the working directory should have been %q, but was %q", "/tmp/a b", cwd)
+		}
+	})
+
+	t.Run("ignores directories on other machines", func(t *testing.T) {
+		c := &Controller{buffer: buffer.New(10, 10)}
+		handleInput(c, "\x1b]7;file:///tmp\x07")
+		handleInput(c, "\x1b]7;file://remote.invalid/home\x07")
+		if cwd := c.WorkingDirectory(); cwd != "" {
+			t.Fatalf("the working directory should be unknown, but was %q", cwd)
+		}
+	})
+
+	t.Run("falls back to the foreground process directory", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("the fallback is only implemented on Linux")
+		}
+		dir := t.TempDir()
+		cmd := exec.Command("sleep", "10")
+		cmd.Dir = dir
+		ptmx, err := pty.Start(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			cmd.Process.Kill()
+			cmd.Wait()
+			ptmx.Close()
+		}()
+		c := &Controller{buffer: buffer.New(10, 10), ptmx: ptmx}
+		if cwd := c.WorkingDirectory(); cwd != dir {
+			t.Fatalf("the working directory should have been %q, but was %q", dir, cwd)
+		}
+		// the foreground process can only change after the program writes something
+		stale := "/stale"
+		c.foregroundCwd = &stale
+		if cwd := c.WorkingDirectory(); cwd != stale {
+			t.Fatalf("the cached working directory %q should have been used, but was %q", stale, cwd)
+		}
+		handleInput(c, "$ ")
+		if cwd := c.WorkingDirectory(); cwd != dir {
+			t.Fatalf("the working directory should have been looked up again after the output, but was %q", cwd)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/viktomas/gritty/buffer"
+	"github.com/viktomas/gritty/parser"
+	"github.com/viktomas/gritty/terminfo"
+)
+
+// translateDCS will get a DCS (Device Control String) operation and enact it on the controller
+// source https://invisible-island.net/xterm/ctlseqs/ctlseqs.html#h3-Device-Control-functions
+func (c *Controller) translateDCS(op parser.Operation) {
+	switch {
+	// XTGETTCAP - Request Termcap/Terminfo String, DCS + q Pt ST
+	case op.Intermediate == "+" && op.R == 'q':
+		c.requestCapabilities(op.Data)
+	// DECRQSS - Request Selection or Setting, DCS $ q Pt ST
+	case op.Intermediate == "$" && op.R == 'q':
+		c.requestStatusString(op.Data)
+	default:
+		fmt.Printf("unknown DCS sequence %v\n", op)
+	}
+}
+
+// requestCapabilities answers XTGETTCAP, Pt are hex-encoded capability names separated by ;
+// every name gets its own reply DCS 1 + r name=value ST with the hex-encoded name and value,
+// unknown names get DCS 0 + r name ST
+func (c *Controller) requestCapabilities(pt string) {
+	var reply strings.Builder
+	for _, encoded := range strings.Split(pt, ";") {
+		name, err := hex.DecodeString(encoded)
+		tc, ok := capability(string(name))
+		switch {
+		case err != nil || !ok:
+			reply.WriteString("\x1bP0+r" + encoded + "\x1b\\")
+		case tc.Kind == terminfo.Bool:
+			// boolean capabilities don't have a value
+			reply.WriteString("\x1bP1+r" + encoded + "\x1b\\")
+		default:
+			reply.WriteString("\x1bP1+r" + encoded + "=" + hex.EncodeToString([]byte(tc.Value)) + "\x1b\\")
+		}
+	}
+	if _, err := c.ptmx.Write([]byte(reply.String())); err != nil {
+		log.Printf("Error when writing capabilities to PTY: %v", err)
+	}
+}
+
+// capability returns the capability from the gritty terminfo entry,
+// besides the terminfo names, xterm answers TN (terminal name) and Co (number of colors)
+func capability(name string) (terminfo.Capability, bool) {
+	switch name {
+	case "TN":
+		return terminfo.Capability{Name: name, Kind: terminfo.String, Value: terminfo.Name}, true
+	case "Co":
+		return terminfo.Lookup("colors")
+	}
+	return terminfo.Lookup(name)
+}
+
+// requestStatusString answers DECRQSS with DCS 1 $ r Pt ST where Pt is the control function that sets the current state,
+// Pt is the final (and intermediate) character of the requested control function, unsupported requests get DCS 0 $ r ST
+// https://vt100.net/docs/vt510-rm/DECRQSS.html
+func (c *Controller) requestStatusString(pt string) {
+	var status string
+	switch pt {
+	case "m": // SGR
+		status = sgrString(c.buffer.Brush()) + "m"
+	case "r": // DECSTBM
+		top, bottom := c.buffer.ScrollArea()
+		status = fmt.Sprintf("%d;%dr", top+1, bottom)
+	case " q": // DECSCUSR
+		status = fmt.Sprintf("%d q", cursorStyleParam(c.buffer.CursorStyle()))
+	case "\"p": // DECSCL
+		status = conformanceLevelStatus
+	}
+	reply := "\x1bP0$r\x1b\\"
+	if status != "" {
+		reply = "\x1bP1$r" + status + "\x1b\\"
+	}
+	if _, err := c.ptmx.Write([]byte(reply)); err != nil {
+		log.Printf("Error when writing status string to PTY: %v", err)
+	}
+}
+
+// cursorStyleParam returns the DECSCUSR parameter that sets the cursor style
+func cursorStyleParam(style buffer.CursorStyle) int {
+	ps := int(style.Shape)*2 + 1
+	if !style.Blinking {
+		ps++
+	}
+	return ps
+}
+
+// reportVersion answers XTVERSION (CSI > 0 q) with DCS > | name(version) ST
+func reportVersion(pty io.Writer) {
+	if _, err := fmt.Fprintf(pty, "\x1bP>|%s(%s)\x1b\\", terminfo.Name, Version); err != nil {
+		log.Printf("Error when writing version to PTY: %v", err)
+	}
+}
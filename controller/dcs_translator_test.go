@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/viktomas/gritty/buffer"
+)
+
+func TestXTGETTCAP(t *testing.T) {
+	c, r := makePipeController(t)
+	query := hex.EncodeToString([]byte("TN")) + ";" + hex.EncodeToString([]byte("colors")) + ";" +
+		hex.EncodeToString([]byte("cup")) + ";" + hex.EncodeToString([]byte("am")) + ";" + hex.EncodeToString([]byte("smxx"))
+	handleInput(c, "\x1bP+q"+query+"\x1b\\")
+	expected := "\x1bP1+r544e=" + hex.EncodeToString([]byte("gritty")) + "\x1b\\" +
+		"\x1bP1+r636f6c6f7273=" + hex.EncodeToString([]byte("256")) + "\x1b\\" +
+		"\x1bP1+r637570=" + hex.EncodeToString([]byte("\x1b[%i%p1%d;%p2%dH")) + "\x1b\\" +
+		"\x1bP1+r616d\x1b\\" +
+		"\x1bP0+r736d7878\x1b\\"
+	if reply := readReply(t, c, r); reply != expected {
+		t.Fatalf("the reply should have been %q, but was %q", expected, reply)
+	}
+}
+
+func TestXTVERSION(t *testing.T) {
+	c, r := makePipeController(t)
+	handleInput(c, "\x1b[>0q")
+	expected := "\x1bP>|gritty(" + Version + ")\x1b\\"
+	if reply := readReply(t, c, r); reply != expected {
+		t.Fatalf("the reply should have been %q, but was %q", expected, reply)
+	}
+}
+
+func TestDECRQSS(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		setup    string
+		request  string
+		expected string
+	}{
+		{desc: "default SGR", request: "m", expected: "\x1bP1$r0m\x1b\\"},
+		{desc: "SGR", setup: "\x1b[1;4;91;48;5;100m", request: "m", expected: "\x1bP1$r0;1;4;91;48;5;100m\x1b\\"},
+		{desc: "SGR true color", setup: "\x1b[7;38;2;1;2;3;42m", request: "m", expected: "\x1bP1$r0;7;38;2;1;2;3;42m\x1b\\"},
+		{desc: "DECSTBM", setup: "\x1b[2;8r", request: "r", expected: "\x1bP1$r2;8r\x1b\\"},
+		{desc: "default DECSTBM", request: "r", expected: "\x1bP1$r1;10r\x1b\\"},
+		{desc: "DECSCUSR", setup: "\x1b[6 q", request: " q", expected: "\x1bP1$r6 q\x1b\\"},
+		{desc: "DECSCL", request: "\"p", expected: "\x1bP1$r61\"p\x1b\\"},
+		{desc: "DECSCL has the DA1 level", setup: "\x1b[c", request: "\"p", expected: "\x1b[?1;2c\x1bP1$r61\"p\x1b\\"},
+		{desc: "unsupported request", request: "t", expected: "\x1bP0$r\x1b\\"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			c, r := makePipeController(t)
+			handleInput(c, tc.setup+"\x1bP$q"+tc.request+"\x1b\\")
+			if reply := readReply(t, c, r); reply != tc.expected {
+				t.Fatalf("the reply should have been %q, but was %q", tc.expected, reply)
+			}
+		})
+	}
+
+	t.Run("SGR reply restores the brush", func(t *testing.T) {
+		c := &Controller{buffer: buffer.New(10, 10)}
+		handleInput(c, "\x1b[5;33;104m")
+		saved := c.buffer.Brush()
+		handleInput(c, "\x1b[0m\x1b["+sgrString(saved)+"m")
+		if c.buffer.Brush() != saved {
+			t.Fatalf("the brush should be %+v, but is %+v", saved, c.buffer.Brush())
+		}
+	})
+}
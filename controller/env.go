@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"os"
+	"strings"
+
+	"github.com/viktomas/gritty/terminfo"
+)
+
+// Version is the gritty version reported to programs in TERM_PROGRAM_VERSION,
+// releases set it with -ldflags "-X github.com/viktomas/gritty/controller.Version=v1.2.3"
+var Version = "dev"
+
+// fallbackTerm is the terminal type used when the gritty terminfo entry isn't installed
+const fallbackTerm = "xterm-256color"
+
+// term returns the terminal type for the TERM variable
+func term() string {
+	if terminfo.Installed() {
+		return terminfo.Name
+	}
+	return fallbackTerm
+}
+
+// terminalEnv identifies gritty to the programs running in it
+func terminalEnv() []string {
+	return []string{
+		"TERM=" + term(),
+		// we support 24-bit colors (SGR 38;2 and 48;2)
+		"COLORTERM=truecolor",
+		"TERM_PROGRAM=gritty",
+		"TERM_PROGRAM_VERSION=" + Version,
+	}
+}
+
+// environ returns the parent environment with the terminal variables and the overrides set and the unset variables removed.
+// Later variables replace the earlier ones with the same name, the variables keep the order of the parent environment.
+func environ(parent, overrides, unset []string) []string {
+	var env []string
+	index := map[string]int{}
+	set := func(kv string) {
+		name, _, _ := strings.Cut(kv, "=")
+		if i, ok := index[name]; ok {
+			env[i] = kv
+			return
+		}
+		index[name] = len(env)
+		env = append(env, kv)
+	}
+	for _, kv := range parent {
+		set(kv)
+	}
+	for _, kv := range terminalEnv() {
+		set(kv)
+	}
+	for _, kv := range overrides {
+		set(kv)
+	}
+	removed := map[string]bool{}
+	for _, name := range unset {
+		removed[name] = true
+	}
+	result := env[:0]
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if !removed[name] {
+			result = append(result, kv)
+		}
+	}
+	return result
+}
+
+// childEnv returns the environment of the program started by the command
+func (cmd Command) childEnv() []string {
+	return environ(os.Environ(), cmd.Env, cmd.Unset)
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEnviron(t *testing.T) {
+	parent := []string{"HOME=/home/user", "TERM=linux", "TMUX=/tmp/tmux", "EDITOR=nano"}
+	env := environ(parent, []string{"EDITOR=vim", "GRITTY_TEST=1"}, []string{"TMUX"})
+	expected := []string{
+		"HOME=/home/user",
+		"TERM=" + term(),
+		"EDITOR=vim",
+		"COLORTERM=truecolor",
+		"TERM_PROGRAM=gritty",
+		"TERM_PROGRAM_VERSION=" + Version,
+		"GRITTY_TEST=1",
+	}
+	if !reflect.DeepEqual(env, expected) {
+		t.Fatalf("expected env\n%v\ngot\n%v", expected, env)
+	}
+
+	t.Run("overrides replace the terminal variables", func(t *testing.T) {
+		env := environ(nil, []string{"TERM=xterm"}, []string{"COLORTERM"})
+		if env[0] != "TERM=xterm" || strings.Contains(strings.Join(env, "\n"), "COLORTERM") {
+			t.Fatalf("unexpected env %v", env)
+		}
+	})
+}
+
+func TestChildEnvironment(t *testing.T) {
+	t.Setenv("GRITTY_INHERITED", "inherited")
+	t.Setenv("GRITTY_OVERRIDDEN", "parent")
+	t.Setenv("GRITTY_UNSET", "parent")
+	t.Setenv("TERM", "dumb")
+	c := &Controller{}
+	runUntilExit(t, c, Command{
+		Path:  "/bin/sh",
+		Args:  []string{"-c", `printf '%s|%s|%s|%s|%s|%s' "$GRITTY_INHERITED" "$GRITTY_OVERRIDDEN" "${GRITTY_UNSET-unset}" "$TERM" "$COLORTERM" "$TERM_PROGRAM"`},
+		Env:   []string{"GRITTY_OVERRIDDEN=config"},
+		Unset: []string{"GRITTY_UNSET"},
+	})
+	expected := "inherited|config|unset|" + term() + "|truecolor|gritty"
+	if screen := c.buffer.String(); !strings.Contains(screen, expected) {
+		t.Fatalf("the program should see %q, but the screen is:\n%s", expected, screen)
+	}
+}
+
+func TestTerm(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "g"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "g", "gritty"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TERMINFO", dir)
+	if term() != "gritty" {
+		t.Fatalf("TERM should be gritty when the terminfo entry is installed, but it is %q", term())
+	}
+}
@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gioui.org/io/key"
+	"github.com/viktomas/gritty/buffer"
+	"github.com/viktomas/gritty/parser"
+)
+
+// supportedKeyboardFlags are the kitty keyboard protocol flags that we implement, programs see only these flags in the query reply.
+// We don't report the associated text because Gio key events don't contain the text.
+const supportedKeyboardFlags = buffer.KeyboardDisambiguate | buffer.KeyboardEventTypes | buffer.KeyboardAlternateKeys | buffer.KeyboardAllKeys
+
+// keyboardProtocol handles the kitty keyboard protocol sequences that change and query the flags
+// https://sw.kovidgoyal.net/kitty/keyboard-protocol/#progressive-enhancement
+func keyboardProtocol(op parser.Operation, b *buffer.Buffer, pty io.Writer) {
+	flags := buffer.KeyboardFlags(op.Param(0, 0)) & supportedKeyboardFlags
+	switch op.Intermediate {
+	// CSI > flags u pushes the flags
+	case ">":
+		b.PushKeyboardFlags(flags)
+	// CSI < number u pops the number of flags
+	case "<":
+		b.PopKeyboardFlags(op.Param(0, 1))
+	// CSI = flags ; mode u, mode 1 sets the flags, 2 sets the given bits and 3 resets them
+	case "=":
+		switch op.Param(1, 1) {
+		case 1:
+			b.SetKeyboardFlags(flags)
+		case 2:
+			b.SetKeyboardFlags(b.KeyboardFlags() | flags)
+		case 3:
+			b.SetKeyboardFlags(b.KeyboardFlags() &^ flags)
+		default:
+			log.Println("unknown keyboard flags mode: ", op)
+		}
+	// CSI ? u queries the current flags
+	case "?":
+		if _, err := fmt.Fprintf(pty, "\x1b[?%du", b.KeyboardFlags()); err != nil {
+			log.Printf("Error when writing keyboard flags to PTY: %v", err)
+		}
+	default:
+		log.Println("unknown CSI u sequence: ", op)
+	}
+}
+
+// functionalKey is a key that kitty encodes as CSI number ; modifiers final
+type functionalKey struct {
+	number int
+	final  byte
+	// legacy is the sequence sent when the key doesn't have modifiers
+	legacy string
+}
+
+// functionalKeys are the keys that don't use the CSI u encoding, they are compatible with xterm
+// https://sw.kovidgoyal.net/kitty/keyboard-protocol/#functional-key-definitions
+var functionalKeys = map[string]functionalKey{
+	key.NameUpArrow:       {number: 1, final: 'A', legacy: "\x1b[A"},
+	key.NameDownArrow:     {number: 1, final: 'B', legacy: "\x1b[B"},
+	key.NameRightArrow:    {number: 1, final: 'C', legacy: "\x1b[C"},
+	key.NameLeftArrow:     {number: 1, final: 'D', legacy: "\x1b[D"},
+	key.NameHome:          {number: 1, final: 'H', legacy: "\x1b[H"},
+	key.NameEnd:           {number: 1, final: 'F', legacy: "\x1b[F"},
+	key.NameDeleteForward: {number: 3, final: '~', legacy: "\x1b[3~"},
+	key.NamePageUp:        {number: 5, final: '~', legacy: "\x1b[5~"},
+	key.NamePageDown:      {number: 6, final: '~', legacy: "\x1b[6~"},
+	"F1":                  {number: 1, final: 'P', legacy: "\x1bOP"},
+	"F2":                  {number: 1, final: 'Q', legacy: "\x1bOQ"},
+	"F3":                  {number: 13, final: '~', legacy: "\x1bOR"}, // ~ because CSI R is the cursor position report
+	"F4":                  {number: 1, final: 'S', legacy: "\x1bOS"},
+	"F5":                  {number: 15, final: '~', legacy: "\x1b[15~"},
+	"F6":                  {number: 17, final: '~', legacy: "\x1b[17~"},
+	"F7":                  {number: 18, final: '~', legacy: "\x1b[18~"},
+	"F8":                  {number: 19, final: '~', legacy: "\x1b[19~"},
+	"F9":                  {number: 20, final: '~', legacy: "\x1b[20~"},
+	"F10":                 {number: 21, final: '~', legacy: "\x1b[21~"},
+	"F11":                 {number: 23, final: '~', legacy: "\x1b[23~"},
+	"F12":                 {number: 24, final: '~', legacy: "\x1b[24~"},
+}
+
+// keyCodes are the unicode key codes of the named keys that use the CSI u encoding
+var keyCodes = map[string]rune{
+	key.NameEscape:         27,
+	key.NameReturn:         13,
+	key.NameTab:            9,
+	key.NameDeleteBackward: 127,
+	key.NameSpace:          ' ',
+	// KP_ENTER from the kitty private use area
+	key.NameEnter: 57414,
+}
+
+// keyCode returns the unicode code of the key, the letter keys have the lower case code (a is 97 even with Shift)
+func keyCode(name string) (rune, bool) {
+	if code, ok := keyCodes[name]; ok {
+		return code, true
+	}
+	r, size := utf8.DecodeRuneInString(name)
+	if r == utf8.RuneError || size != len(name) {
+		return 0, false
+	}
+	return unicode.ToLower(r), true
+}
+
+// kitty modifier bits, the encoded modifiers are 1 + bits
+// Gio doesn't tell the left and right modifier keys apart, so we don't report the modifier keys themselves
+const (
+	kittyShift = 1
+	kittyAlt   = 2
+	kittyCtrl  = 4
+	kittySuper = 8
+)
+
+func kittyModifiers(mod key.Modifiers) int {
+	var mods int
+	if mod.Contain(key.ModShift) {
+		mods |= kittyShift
+	}
+	if mod.Contain(key.ModAlt) {
+		mods |= kittyAlt
+	}
+	if mod.Contain(key.ModCtrl) {
+		mods |= kittyCtrl
+	}
+	if mod.Contain(key.ModSuper) || mod.Contain(key.ModCommand) {
+		mods |= kittySuper
+	}
+	return mods
+}
+
+// encodeKey returns the bytes that the key sends to the program, nil means the key doesn't send anything.
+// With the kitty keyboard protocol flags, keys use the CSI code ; modifiers u encoding, otherwise the legacy keyToBytes.
+// https://sw.kovidgoyal.net/kitty/keyboard-protocol/#disambiguate-escape-codes
+func encodeKey(name string, mod key.Modifiers, state key.State, flags buffer.KeyboardFlags) []byte {
+	release := state == key.Release
+	if release && flags&buffer.KeyboardEventTypes == 0 {
+		return nil
+	}
+	legacy := func() []byte {
+		// legacy encoding doesn't report releases
+		if release {
+			return nil
+		}
+		return keyToBytes(name, mod)
+	}
+	if flags == 0 {
+		return legacy()
+	}
+	mods := kittyModifiers(mod)
+	allKeys := flags&buffer.KeyboardAllKeys != 0
+	if fk, ok := functionalKeys[name]; ok {
+		// the legacy encoding can't report releases, so they always use the kitty sequence
+		if !allKeys && mods == 0 && !release {
+			return []byte(fk.legacy)
+		}
+		return kittySequence(fk.number, 0, mods, release, fk.final)
+	}
+	code, ok := keyCode(name)
+	if !ok {
+		return legacy()
+	}
+	if !allKeys {
+		switch code {
+		// Esc is always encoded, so it can't be mistaken for the start of an escape sequence
+		case 27:
+		// Enter, Tab and Backspace without modifiers stay the same, so the user can type reset when a program leaves the flags on
+		case 13, 9, 127:
+			if mods == 0 {
+				return legacy()
+			}
+		default:
+			// keys that produce text are sent as text
+			if mods&^kittyShift == 0 {
+				return legacy()
+			}
+		}
+	}
+	var shifted rune
+	if flags&buffer.KeyboardAlternateKeys != 0 && mods&kittyShift != 0 && unicode.ToUpper(code) != code {
+		shifted = unicode.ToUpper(code)
+	}
+	return kittySequence(int(code), shifted, mods, release, 'u')
+}
+
+// kittySequence returns CSI number[:shifted] ; modifiers[:event] final,
+// the modifiers are left out for the key press without modifiers and so is the number 1 of the functional keys
+func kittySequence(number int, shifted rune, mods int, release bool, final byte) []byte {
+	var s strings.Builder
+	s.WriteString("\x1b[")
+	withModifiers := mods != 0 || release
+	if number != 1 || withModifiers {
+		s.WriteString(strconv.Itoa(number))
+	}
+	if shifted != 0 {
+		s.WriteString(":" + strconv.Itoa(int(shifted)))
+	}
+	if withModifiers {
+		s.WriteString(";" + strconv.Itoa(mods+1))
+		if release {
+			s.WriteString(":3")
+		}
+	}
+	s.WriteByte(final)
+	return []byte(s.String())
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"testing"
+
+	"gioui.org/io/key"
+	"github.com/viktomas/gritty/buffer"
+)
+
+func TestKeyboardProtocolFlags(t *testing.T) {
+	c, r := makePipeController(t)
+	handleInput(c, "\x1b[>1u\x1b[?u")
+	handleInput(c, "\x1b[=4;2u\x1b[?u")
+	handleInput(c, "\x1b[=1;3u\x1b[?u")
+	// the associated text (16) isn't supported
+	handleInput(c, "\x1b[>31u\x1b[?u")
+	handleInput(c, "\x1b[<u\x1b[?u")
+	handleInput(c, "\x1b[<5u\x1b[?u")
+	expected := "\x1b[?1u\x1b[?5u\x1b[?4u\x1b[?15u\x1b[?4u\x1b[?0u"
+	if reply := readReply(t, c, r); reply != expected {
+		t.Fatalf("the reply should have been %q, but was %q", expected, reply)
+	}
+}
+
+func TestEncodeKey(t *testing.T) {
+	const (
+		disambiguate = buffer.KeyboardDisambiguate
+		eventTypes   = buffer.KeyboardEventTypes
+		alternate    = buffer.KeyboardAlternateKeys
+		allKeys      = buffer.KeyboardAllKeys
+	)
+	testCases := []struct {
+		desc     string
+		name     string
+		mod      key.Modifiers
+		release  bool
+		flags    buffer.KeyboardFlags
+		expected string
+	}{
+		{desc: "legacy text", name: "A", expected: "a"},
+		{desc: "legacy release", name: "A", release: true},
+		{desc: "legacy Ctrl+I is Tab", name: "I", mod: key.ModCtrl, expected: "\t"},
+		{desc: "Ctrl+I", name: "I", mod: key.ModCtrl, flags: disambiguate, expected: "\x1b[105;5u"},
+		{desc: "Tab", name: key.NameTab, flags: disambiguate, expected: "\t"},
+		{desc: "Ctrl+[", name: "[", mod: key.ModCtrl, flags: disambiguate, expected: "\x1b[91;5u"},
+		{desc: "Escape", name: key.NameEscape, flags: disambiguate, expected: "\x1b[27u"},
+		{desc: "text", name: "A", flags: disambiguate, expected: "a"},
+		{desc: "shifted text", name: "A", mod: key.ModShift, flags: disambiguate, expected: "A"},
+		{desc: "Alt+A", name: "A", mod: key.ModAlt, flags: disambiguate, expected: "\x1b[97;3u"},
+		{desc: "Shift+Enter", name: key.NameReturn, mod: key.ModShift, flags: disambiguate, expected: "\x1b[13;2u"},
+		{desc: "Up", name: key.NameUpArrow, flags: disambiguate, expected: "\x1b[A"},
+		{desc: "Ctrl+Up", name: key.NameUpArrow, mod: key.ModCtrl, flags: disambiguate, expected: "\x1b[1;5A"},
+		{desc: "F3", name: "F3", flags: disambiguate, expected: "\x1bOR"},
+		{desc: "Shift+F5", name: "F5", mod: key.ModShift, flags: disambiguate, expected: "\x1b[15;2~"},
+		{desc: "release without event types", name: "I", mod: key.ModCtrl, release: true, flags: disambiguate},
+		{desc: "release", name: "I", mod: key.ModCtrl, release: true, flags: disambiguate | eventTypes, expected: "\x1b[105;5:3u"},
+		{desc: "Escape release", name: key.NameEscape, release: true, flags: disambiguate | eventTypes, expected: "\x1b[27;1:3u"},
+		{desc: "Up release", name: key.NameUpArrow, release: true, flags: disambiguate | eventTypes, expected: "\x1b[1;1:3A"},
+		{desc: "text release isn't reported", name: "A", release: true, flags: disambiguate | eventTypes},
+		{desc: "alternate key", name: "A", mod: key.ModCtrl | key.ModShift, flags: disambiguate | alternate, expected: "\x1b[97:65;6u"},
+		{desc: "all keys text", name: "A", flags: allKeys, expected: "\x1b[97u"},
+		{desc: "all keys Enter", name: key.NameReturn, flags: allKeys, expected: "\x1b[13u"},
+		{desc: "all keys Up", name: key.NameUpArrow, flags: allKeys, expected: "\x1b[A"},
+		{desc: "all keys shifted text", name: "A", mod: key.ModShift, flags: allKeys | alternate, expected: "\x1b[97:65;2u"},
+		{desc: "all keys text release", name: "A", release: true, flags: allKeys | eventTypes, expected: "\x1b[97;1:3u"},
+		{desc: "all keys Up release", name: key.NameUpArrow, release: true, flags: allKeys | eventTypes, expected: "\x1b[1;1:3A"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			state := key.Press
+			if tc.release {
+				state = key.Release
+			}
+			if got := string(encodeKey(tc.name, tc.mod, state, tc.flags)); got != tc.expected {
+				t.Fatalf("the key should send %q, but it sent %q", tc.expected, got)
+			}
+		})
+	}
+}
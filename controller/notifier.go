@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Notification is a desktop notification that the program requested with OSC 9 or OSC 777
+type Notification struct {
+	// Title can be empty, OSC 9 notifications have only the body
+	Title string
+	Body  string
+}
+
+// Notifier shows desktop notifications
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// CommandNotifier shows notifications by running a command (e.g. notify-send),
+// the title and the body are passed to the command as the last two arguments
+type CommandNotifier struct {
+	Command []string
+}
+
+func (cn CommandNotifier) Notify(n Notification) error {
+	if len(cn.Command) == 0 {
+		return fmt.Errorf("the notification command is empty")
+	}
+	args := append(cn.Command[1:len(cn.Command):len(cn.Command)], n.Title, n.Body)
+	if n.Title == "" {
+		// notification commands usually require the title (summary)
+		args = append(cn.Command[1:len(cn.Command):len(cn.Command)], n.Body)
+	}
+	return startCommand(cn.Command[0], args...)
+}
+
+// startCommand starts the command without waiting for it to finish
+func startCommand(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// Bell configures what happens when the program rings the bell (BEL)
+type Bell struct {
+	// Visual flashes the window
+	Visual bool
+	// Urgent asks the window manager for attention when the window doesn't have focus
+	Urgent bool
+	// Command runs on every bell (e.g. to play a sound), empty means no command
+	Command []string
+}
+
+// minBellCommandInterval limits how often the bell command runs, programs sometimes ring the bell many times in a row
+const minBellCommandInterval = 100 * time.Millisecond
+
+// bell rings the bell, the GUI finds out about it with TakeBell
+func (c *Controller) bell() {
+	c.bellRung = true
+	if len(c.Bell.Command) == 0 || time.Since(c.lastBellCommand) < minBellCommandInterval {
+		return
+	}
+	c.lastBellCommand = time.Now()
+	if err := startCommand(c.Bell.Command[0], c.Bell.Command[1:]...); err != nil {
+		log.Printf("running the bell command failed: %v", err)
+	}
+}
+
+// TakeBell returns true if the program rang the bell since the last call
+func (c *Controller) TakeBell() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rung := c.bellRung
+	c.bellRung = false
+	return rung
+}
+
+// notify shows the notification using the Notifier, if there is no Notifier, the notification is ignored
+func (c *Controller) notify(n Notification) {
+	if c.Notifier == nil {
+		return
+	}
+	if err := c.Notifier.Notify(n); err != nil {
+		log.Printf("showing the notification failed: %v", err)
+	}
+}
+
+// notificationOSC handles OSC 9 ; body (iTerm2), OSC 9 ; 4 ; ... is ConEmu progress report and we ignore it
+// https://iterm2.com/documentation-escape-codes.html
+func (c *Controller) notificationOSC(pt string) {
+	if strings.HasPrefix(pt, "4;") {
+		return
+	}
+	c.notify(Notification{Body: pt})
+}
+
+// notifyOSC handles OSC 777 ; notify ; title ; body (urxvt and VTE)
+func (c *Controller) notifyOSC(pt string) {
+	kind, rest, _ := strings.Cut(pt, ";")
+	if kind != "notify" {
+		fmt.Println("unhandled OSC 777: ", pt)
+		return
+	}
+	title, body, _ := strings.Cut(rest, ";")
+	c.notify(Notification{Title: title, Body: body})
+}
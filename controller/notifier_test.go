@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/viktomas/gritty/buffer"
+)
+
+type fakeNotifier struct {
+	notifications []Notification
+}
+
+func (f *fakeNotifier) Notify(n Notification) error {
+	f.notifications = append(f.notifications, n)
+	return nil
+}
+
+func TestNotifications(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		input    string
+		expected []Notification
+	}{
+		{desc: "OSC 9", input: "\x1b]9;build finished\x07", expected: []Notification{{Body: "build finished"}}},
+		{desc: "OSC 9 progress is ignored", input: "\x1b]9;4;1;50\x07"},
+		{desc: "OSC 777", input: "\x1b]777;notify;make;build finished\x1b\\", expected: []Notification{{Title: "make", Body: "build finished"}}},
+		{desc: "OSC 777 without notify is ignored", input: "\x1b]777;precmd\x07"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			notifier := &fakeNotifier{}
+			c := &Controller{buffer: buffer.New(10, 10), Notifier: notifier}
+			handleInput(c, tc.input)
+			if !reflect.DeepEqual(notifier.notifications, tc.expected) {
+				t.Fatalf("expected notifications %v, got %v", tc.expected, notifier.notifications)
+			}
+		})
+	}
+
+	t.Run("notifications without a notifier are ignored", func(t *testing.T) {
+		c := &Controller{buffer: buffer.New(10, 10)}
+		handleInput(c, "\x1b]9;build finished\x07")
+	})
+}
+
+func TestBell(t *testing.T) {
+	c := &Controller{buffer: buffer.New(10, 10)}
+	if c.TakeBell() {
+		t.Fatalf("the bell shouldn't ring before BEL")
+	}
+	handleInput(c, "\a\a")
+	if !c.TakeBell() {
+		t.Fatalf("the bell should ring after BEL")
+	}
+	if c.TakeBell() {
+		t.Fatalf("the bell should be taken only once")
+	}
+}
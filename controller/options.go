@@ -0,0 +1,49 @@
+package controller
+
+import "github.com/viktomas/gritty/buffer"
+
+// Options are the user settings that can change while the program runs (e.g. when the user edits the config file)
+type Options struct {
+	// Theme is the palette that programs can change with OSC 4, 10, 11 and 12 and reset back to
+	Theme buffer.Palette
+	// Scrollback is the maximum number of lines in the scrollback
+	Scrollback int
+	// CursorStyle is the cursor style that programs reset the cursor to
+	CursorStyle        buffer.CursorStyle
+	AllowClipboardRead bool
+	Bell               Bell
+}
+
+// DefaultOptions returns the options that gritty uses if the user doesn't configure anything
+func DefaultOptions() Options {
+	return Options{
+		Theme:       buffer.DefaultPalette(),
+		Scrollback:  buffer.DefaultScrollback,
+		CursorStyle: buffer.DefaultCursorStyle,
+		Bell:        Bell{Visual: true, Urgent: true},
+	}
+}
+
+// SetOptions applies the options, if the controller hasn't started yet, the options apply when it starts.
+// Changing the theme resets the colors that the program changed.
+func (c *Controller) SetOptions(o Options) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.options = &o
+	c.AllowClipboardRead = o.AllowClipboardRead
+	c.Bell = o.Bell
+	if c.buffer != nil {
+		c.applyOptions()
+		c.requestRender()
+	}
+}
+
+// applyOptions sets the options that the buffer keeps
+func (c *Controller) applyOptions() {
+	if c.options == nil {
+		return
+	}
+	c.buffer.SetTheme(c.options.Theme)
+	c.buffer.SetMaxScrollback(c.options.Scrollback)
+	c.buffer.SetDefaultCursorStyle(c.options.CursorStyle)
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/viktomas/gritty/buffer"
+)
+
+func TestSetOptions(t *testing.T) {
+	o := DefaultOptions()
+	o.Theme.FG = buffer.RGB{R: 1, G: 2, B: 3}
+	o.Scrollback = 1
+	o.CursorStyle = buffer.CursorStyle{Shape: buffer.CursorBar}
+	o.AllowClipboardRead = true
+
+	t.Run("applies the options to the running terminal", func(t *testing.T) {
+		c, _ := makePipeController(t)
+		c.SetOptions(o)
+		handleInput(c, "a\r\nb\r\nc\r\nd\r\ne\r\nf\r\ng\r\nh\r\ni\r\nj\r\nk\r\nl")
+		if c.Palette().FG != o.Theme.FG {
+			t.Fatalf("the palette should use the theme foreground %v, but it is %v", o.Theme.FG, c.Palette().FG)
+		}
+		if c.buffer.ScrollbackLen() != 1 {
+			t.Fatalf("the scrollback should contain 1 line, but it contains %d", c.buffer.ScrollbackLen())
+		}
+		if !c.AllowClipboardRead {
+			t.Fatalf("the clipboard read should be allowed")
+		}
+	})
+
+	t.Run("programs reset the cursor to the configured style", func(t *testing.T) {
+		c, _ := makePipeController(t)
+		c.SetOptions(o)
+		handleInput(c, "\x1b[4 q\x1b[0 q")
+		if _, style, _ := c.Cursor(); style != o.CursorStyle {
+			t.Fatalf("the cursor style should be %v, but it is %v", o.CursorStyle, style)
+		}
+	})
+
+	t.Run("only parameter 0 resets the cursor to the configured style", func(t *testing.T) {
+		c, _ := makePipeController(t)
+		c.SetOptions(o)
+		handleInput(c, "\x1b[1 q")
+		expected := buffer.CursorStyle{Shape: buffer.CursorBlock, Blinking: true}
+		if _, style, _ := c.Cursor(); style != expected {
+			t.Fatalf("the cursor style should be %v, but it is %v", expected, style)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/viktomas/gritty/buffer"
+	"github.com/viktomas/gritty/parser"
+)
+
+// translateOSC will get an OSC (Operating System Command) operation and enact it on the controller
+// the OSC has format Ps ; Pt, where Ps is a number identifying the command and Pt are the command arguments
+// source https://invisible-island.net/xterm/ctlseqs/ctlseqs.html#h3-Operating-System-Commands
+func (c *Controller) translateOSC(op parser.Operation) {
+	ps, pt, _ := strings.Cut(op.Osc, ";")
+	switch ps {
+	// Change Icon Name and Window Title to Pt, we don't have icon name so we only change the title
+	case "0", "2":
+		c.title = pt
+	case "4":
+		c.paletteOSC(op, pt)
+	case "10", "11", "12":
+		n, _ := strconv.Atoi(ps)
+		c.dynamicColorOSC(op, n, pt)
+	case "104":
+		c.resetPaletteOSC(pt)
+	case "110", "111", "112":
+		n, _ := strconv.Atoi(ps)
+		// OSC 110 resets the color changed by OSC 10
+		c.resetDynamicColorOSC(n - 100)
+	case "7":
+		c.workingDirectoryOSC(pt)
+	case "8":
+		c.hyperlink(pt)
+	case "133":
+		c.promptMark(pt)
+	case "9":
+		c.notificationOSC(pt)
+	case "777":
+		c.notifyOSC(pt)
+	case "52":
+		pc, pd, _ := strings.Cut(pt, ";")
+		c.clipboardOSC(pc, pd)
+	default:
+		fmt.Println("unhandled OSC instruction: ", op)
+	}
+}
+
+// hyperlink handles OSC 8 ; params ; URI, an empty URI ends the link
+// params are key=value pairs separated by :, we only use the id key
+// https://gist.github.com/egmontkob/eb114294efbcd5adb1944c9f3cb5feda
+func (c *Controller) hyperlink(pt string) {
+	params, uri, ok := strings.Cut(pt, ";")
+	if !ok || uri == "" {
+		c.buffer.EndLink()
+		return
+	}
+	var id string
+	for _, param := range strings.Split(params, ":") {
+		if v, ok := strings.CutPrefix(param, "id="); ok {
+			id = v
+		}
+	}
+	c.buffer.StartLink(id, uri)
+}
+
+// promptMark handles OSC 133 ; mark [; params], the shell integration marks
+// A - prompt start, B - command start, C - command output start, D [; exit code] - command finished
+// https://gitlab.freedesktop.org/Per_Bothner/specifications/blob/master/proposals/semantic-prompts.md
+func (c *Controller) promptMark(pt string) {
+	mark, params, _ := strings.Cut(pt, ";")
+	switch mark {
+	case "A":
+		c.buffer.Mark(buffer.MarkPrompt)
+	case "B":
+		c.buffer.Mark(buffer.MarkCommand)
+	case "C":
+		c.buffer.Mark(buffer.MarkOutput)
+	case "D":
+		exitCode, _, _ := strings.Cut(params, ";")
+		// a missing exit code means that the command didn't run (e.g. the user pressed Ctrl+C)
+		code, err := strconv.Atoi(exitCode)
+		if err != nil {
+			code = 0
+		}
+		c.buffer.CommandFinished(code)
+	default:
+		fmt.Println("unhandled OSC 133 mark: ", pt)
+	}
+}
@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/viktomas/gritty/buffer"
+	"github.com/viktomas/gritty/parser"
+)
+
+// paletteOSC handles OSC 4 ; c ; spec [; c ; spec ...], it changes the palette color c to spec
+// if spec is ?, we reply with the current color instead
+// https://invisible-island.net/xterm/ctlseqs/ctlseqs.html#h3-Operating-System-Commands
+func (c *Controller) paletteOSC(op parser.Operation, pt string) {
+	p := c.buffer.Palette()
+	params := strings.Split(pt, ";")
+	for i := 0; i+1 < len(params); i += 2 {
+		index, err := strconv.ParseUint(params[i], 10, 8)
+		if err != nil {
+			log.Printf("invalid color index in OSC 4: %q", params[i])
+			continue
+		}
+		spec := params[i+1]
+		if spec == "?" {
+			c.replyOSC(op, fmt.Sprintf("4;%d;%s", index, formatColorSpec(p.Indexed[index])))
+			continue
+		}
+		if rgb, ok := parseColorSpec(spec); ok {
+			p.Indexed[index] = rgb
+		} else {
+			log.Printf("invalid color in OSC 4: %q", spec)
+		}
+	}
+	c.setPalette(p)
+}
+
+// resetPaletteOSC handles OSC 104 [; c ...], it resets the palette colors c to the theme, no c resets all palette colors
+func (c *Controller) resetPaletteOSC(pt string) {
+	p, theme := c.buffer.Palette(), c.buffer.Theme()
+	if pt == "" {
+		p.Indexed = theme.Indexed
+	}
+	for _, param := range strings.Split(pt, ";") {
+		if index, err := strconv.ParseUint(param, 10, 8); err == nil {
+			p.Indexed[index] = theme.Indexed[index]
+		}
+	}
+	c.setPalette(p)
+}
+
+// dynamicColorOSC handles OSC 10, 11 and 12 ; spec [; spec ...], they change the foreground, background and cursor colors
+// each following spec changes the next color, e.g. OSC 10 ; fg ; bg changes both foreground and background
+// if spec is ?, we reply with the current color instead
+func (c *Controller) dynamicColorOSC(op parser.Operation, ps int, pt string) {
+	p := c.buffer.Palette()
+	for i, spec := range strings.Split(pt, ";") {
+		n := ps + i
+		color := dynamicColor(&p, n)
+		if color == nil {
+			break
+		}
+		if spec == "?" {
+			c.replyOSC(op, fmt.Sprintf("%d;%s", n, formatColorSpec(*color)))
+			continue
+		}
+		if rgb, ok := parseColorSpec(spec); ok {
+			*color = rgb
+		} else {
+			log.Printf("invalid color in OSC %d: %q", n, spec)
+		}
+	}
+	c.setPalette(p)
+}
+
+// resetDynamicColorOSC handles OSC 110, 111 and 112, they reset the foreground, background and cursor colors to the theme
+func (c *Controller) resetDynamicColorOSC(ps int) {
+	p, theme := c.buffer.Palette(), c.buffer.Theme()
+	*dynamicColor(&p, ps) = *dynamicColor(&theme, ps)
+	c.setPalette(p)
+}
+
+// setPalette changes the palette only if a color changed, a new palette repaints the whole screen
+// so the color queries (e.g. OSC 11 ; ? that vim sends at startup) mustn't set it
+func (c *Controller) setPalette(p buffer.Palette) {
+	if p != c.buffer.Palette() {
+		c.buffer.SetPalette(p)
+	}
+}
+
+// dynamicColor returns the palette color changed by OSC ps (10 - foreground, 11 - background, 12 - cursor)
+func dynamicColor(p *buffer.Palette, ps int) *buffer.RGB {
+	switch ps {
+	case 10:
+		return &p.FG
+	case 11:
+		return &p.BG
+	case 12:
+		return &p.Cursor
+	}
+	return nil
+}
+
+// replyOSC sends the OSC to the PTY with the same terminator (BEL or ST) as the op that we reply to
+func (c *Controller) replyOSC(op parser.Operation, reply string) {
+	terminator := "\x1b\\"
+	if len(op.Raw) > 0 && op.Raw[len(op.Raw)-1] == asciiBEL {
+		terminator = "\a"
+	}
+	_, err := fmt.Fprintf(c.ptmx, "\x1b]%s%s", reply, terminator)
+	if err != nil {
+		log.Printf("Error when writing OSC reply to PTY: %v", err)
+	}
+}
+
+// formatColorSpec returns the color in the xterm format rgb:rrrr/gggg/bbbb
+func formatColorSpec(c buffer.RGB) string {
+	// 0xff * 257 = 0xffff
+	return fmt.Sprintf("rgb:%04x/%04x/%04x", uint16(c.R)*257, uint16(c.G)*257, uint16(c.B)*257)
+}
+
+// parseColorSpec parses the XParseColor formats rgb:r/g/b (each component with 1 to 4 hex digits)
+// and #rgb (with 1 to 4 hex digits per component)
+func parseColorSpec(spec string) (buffer.RGB, bool) {
+	var components []string
+	if s, ok := strings.CutPrefix(spec, "rgb:"); ok {
+		components = strings.Split(s, "/")
+	} else if s, ok := strings.CutPrefix(spec, "#"); ok && len(s) > 0 && len(s)%3 == 0 {
+		n := len(s) / 3
+		components = []string{s[:n], s[n : 2*n], s[2*n:]}
+	}
+	if len(components) != 3 {
+		return buffer.RGB{}, false
+	}
+	var rgb [3]uint8
+	for i, c := range components {
+		if len(c) < 1 || len(c) > 4 {
+			return buffer.RGB{}, false
+		}
+		v, err := strconv.ParseUint(c, 16, 16)
+		if err != nil {
+			return buffer.RGB{}, false
+		}
+		// scale the component from len(c) hex digits to 8 bits
+		maxValue := uint64(1)<<(4*len(c)) - 1
+		rgb[i] = uint8((v*255 + maxValue/2) / maxValue)
+	}
+	return buffer.RGB{R: rgb[0], G: rgb[1], B: rgb[2]}, true
+}
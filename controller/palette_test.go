@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/viktomas/gritty/buffer"
+)
+
+func TestPaletteOSC(t *testing.T) {
+	t.Run("answers color queries with the same terminator", func(t *testing.T) {
+		c, r := makePipeController(t)
+		handleInput(c, "\x1b]11;?\x07\x1b]4;1;?\x1b\\")
+		expected := "\x1b]11;rgb:2828/2828/2828\a\x1b]4;1;rgb:cdcd/3131/3131\x1b\\"
+		if reply := readReply(t, c, r); reply != expected {
+			t.Fatalf("the reply should have been %q, but was %q", expected, reply)
+		}
+	})
+
+	t.Run("queries don't change the palette", func(t *testing.T) {
+		c, _ := makePipeController(t)
+		c.buffer.TakeDamage()
+		handleInput(c, "\x1b]11;?\x07\x1b]4;1;?\x07\x1b]10;#ebdbb2\x07")
+		if damage := c.buffer.TakeDamage(); len(damage) != 0 {
+			t.Fatalf("no row should have been damaged, but rows %v were", damage)
+		}
+	})
+
+	t.Run("changes and resets palette colors", func(t *testing.T) {
+		c := &Controller{buffer: buffer.New(10, 10)}
+		handleInput(c, "\x1b]4;1;#ff0000;2;rgb:0/f/0\x07")
+		p := c.buffer.Palette()
+		if p.Indexed[1] != (buffer.RGB{R: 0xff}) || p.Indexed[2] != (buffer.RGB{G: 0xff}) {
+			t.Fatalf("colors 1 and 2 should have changed, but are %v and %v", p.Indexed[1], p.Indexed[2])
+		}
+		handleInput(c, "\x1b]104;1\x07")
+		p = c.buffer.Palette()
+		if p.Indexed[1] != buffer.DefaultPalette().Indexed[1] || p.Indexed[2] != (buffer.RGB{G: 0xff}) {
+			t.Fatalf("only color 1 should have been reset, colors are %v and %v", p.Indexed[1], p.Indexed[2])
+		}
+		handleInput(c, "\x1b]104\x07")
+		if c.buffer.Palette() != buffer.DefaultPalette() {
+			t.Fatalf("all colors should have been reset")
+		}
+	})
+
+	t.Run("changes and resets dynamic colors", func(t *testing.T) {
+		c := &Controller{buffer: buffer.New(10, 10)}
+		handleInput(c, "\x1b]10;#010203;#040506\x07\x1b]12;#070809\x07")
+		p := c.buffer.Palette()
+		if p.FG != (buffer.RGB{R: 1, G: 2, B: 3}) || p.BG != (buffer.RGB{R: 4, G: 5, B: 6}) || p.Cursor != (buffer.RGB{R: 7, G: 8, B: 9}) {
+			t.Fatalf("foreground, background and cursor should have changed, the palette is %v, %v, %v", p.FG, p.BG, p.Cursor)
+		}
+		handleInput(c, "\x1b]110\x07\x1b]111\x07\x1b]112\x07")
+		if c.buffer.Palette() != buffer.DefaultPalette() {
+			t.Fatalf("all dynamic colors should have been reset")
+		}
+	})
+}
+
+func TestParseColorSpec(t *testing.T) {
+	testCases := []struct {
+		spec     string
+		expected buffer.RGB
+		ok       bool
+	}{
+		{spec: "rgb:ff/80/00", expected: buffer.RGB{R: 0xff, G: 0x80, B: 0x00}, ok: true},
+		{spec: "rgb:f/8/0", expected: buffer.RGB{R: 0xff, G: 0x88, B: 0x00}, ok: true},
+		{spec: "rgb:ffff/8080/0000", expected: buffer.RGB{R: 0xff, G: 0x80, B: 0x00}, ok: true},
+		{spec: "#f80", expected: buffer.RGB{R: 0xff, G: 0x88, B: 0x00}, ok: true},
+		{spec: "#ff8000", expected: buffer.RGB{R: 0xff, G: 0x80, B: 0x00}, ok: true},
+		{spec: "rgb:ff/80", ok: false},
+		{spec: "rgb:fffff/0/0", ok: false},
+		{spec: "#ff800", ok: false},
+		{spec: "red", ok: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.spec, func(t *testing.T) {
+			rgb, ok := parseColorSpec(tc.spec)
+			if ok != tc.ok || rgb != tc.expected {
+				t.Fatalf("expected %v (%v), got %v (%v)", tc.expected, tc.ok, rgb, ok)
+			}
+		})
+	}
+}
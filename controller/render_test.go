@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/viktomas/gritty/buffer"
+	"github.com/viktomas/gritty/parser"
+)
+
+// catOutput returns output similar to cat-ing a large colored log file
+func catOutput(lines int) []byte {
+	var out bytes.Buffer
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&out, "\x1b[32m%6d\x1b[0m the quick brown fox jumps over the lazy dog %d\r\n", i, i*i)
+	}
+	return out.Bytes()
+}
+
+// runCat feeds the output to the controller and simulates the GUI reading the screen on every render signal
+// it returns the number of render signals
+func runCat(c *Controller, output []byte) int {
+	c.run(io.NopCloser(bytes.NewReader(output)))
+	renders := 0
+	for {
+		select {
+		case _, ok := <-c.Render():
+			if !ok {
+				return renders
+			}
+			renders++
+			c.Runes()
+		case <-c.Done:
+			return renders
+		}
+	}
+}
+
+func TestRenderCoalescing(t *testing.T) {
+	c := &Controller{buffer: buffer.New(80, 24)}
+	output := catOutput(10000)
+	start := time.Now()
+	renders := runCat(c, output)
+	elapsed := time.Since(start)
+	// each PTY read produces at most one render signal and the signals are capped at one per frame
+	maxRenders := len(output)/ptyReadSize + 1
+	if renders > maxRenders {
+		t.Fatalf("expected at most %d render signals, but got %d", maxRenders, renders)
+	}
+	if frames := int(elapsed/minFrameInterval) + 1; renders > frames {
+		t.Fatalf("expected at most %d render signals in %v, but got %d", frames, elapsed, renders)
+	}
+}
+
+func TestFinalRender(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		c := &Controller{buffer: buffer.New(80, 24)}
+		c.run(io.NopCloser(bytes.NewReader([]byte("bye"))))
+		// the GUI finds out about the exit before it reads the render signal
+		<-c.Done
+		select {
+		case _, ok := <-c.Render():
+			if !ok {
+				t.Fatal("the output written before the exit should get rendered before the render channel closes")
+			}
+		case <-time.After(finalRenderTimeout / 2):
+			t.Fatal("the output written before the exit should get rendered")
+		}
+	}
+}
+
+// renderEveryOp is how the controller worked before the render coalescing, the GUI read the screen after every operation
+func renderEveryOp(c *Controller, output []byte) {
+	p := parser.New()
+	for start := 0; start < len(output); start += ptyReadSize {
+		for _, op := range p.Parse(output[start:min(start+ptyReadSize, len(output))]) {
+			c.handleOp(op)
+			c.Runes()
+		}
+	}
+}
+
+// BenchmarkCat compares the render coalescing with the rendering after every operation
+func BenchmarkCat(b *testing.B) {
+	output := catOutput(10000)
+	b.Run("coalesced", func(b *testing.B) {
+		b.SetBytes(int64(len(output)))
+		for i := 0; i < b.N; i++ {
+			runCat(&Controller{buffer: buffer.New(80, 24)}, output)
+		}
+	})
+	b.Run("render every operation", func(b *testing.B) {
+		b.SetBytes(int64(len(output)))
+		for i := 0; i < b.N; i++ {
+			renderEveryOp(&Controller{buffer: buffer.New(80, 24)}, output)
+		}
+	})
+}
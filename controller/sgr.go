@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/viktomas/gritty/buffer"
+)
+
+// applySGR changes the brush with all SGR (Select Graphic Rendition) parameters, no parameters reset the brush
+// https://vt100.net/docs/vt510-rm/SGR.html
+func applySGR(params []int, br buffer.Brush) buffer.Brush {
+	if len(params) == 0 {
+		params = []int{0}
+	}
+	for i := 0; i < len(params); i++ {
+		ps := params[i]
+		switch {
+		// 4bit color
+		case ps >= 30 && ps <= 37:
+			br.FG = buffer.IndexedColor(uint8(ps - 30))
+		case ps >= 90 && ps <= 97:
+			br.FG = buffer.IndexedColor(uint8(ps - 90 + 8))
+		case ps >= 40 && ps <= 47:
+			br.BG = buffer.IndexedColor(uint8(ps - 40))
+		case ps >= 100 && ps <= 107:
+			br.BG = buffer.IndexedColor(uint8(ps - 100 + 8))
+		case ps == 0:
+			// DECSCA protection isn't a graphic rendition, SGR 0 must keep it
+			br = buffer.Brush{Protected: br.Protected}
+		case ps == 1:
+			br.Bold = true
+		case ps == 4:
+			br.Underline = true
+		case ps == 5:
+			br.Blink = true
+		case ps == 7:
+			br.Invert = true
+		case ps == 22:
+			br.Bold = false
+		case ps == 24:
+			br.Underline = false
+		case ps == 25:
+			br.Blink = false
+		case ps == 27:
+			br.Invert = false
+		case ps == 38 || ps == 48:
+			// 38;5;Ps is indexed and 38;2;Pr;Pg;Pb is true color, 48 is the same for the background
+			color, n, ok := extendedColor(params[i+1:])
+			if !ok {
+				log.Printf("unknown SGR extended color %v\n", params[i:])
+				return br
+			}
+			if ps == 38 {
+				br.FG = color
+			} else {
+				br.BG = color
+			}
+			i += n
+		case ps == 39:
+			br.FG = buffer.DefaultColor
+		case ps == 49:
+			br.BG = buffer.DefaultColor
+		default:
+			log.Printf("unknown SGR instruction %d\n", ps)
+		}
+	}
+	return br
+}
+
+// extendedColor reads the color that follows SGR 38 or 48, it returns how many parameters the color used
+func extendedColor(params []int) (buffer.Color, int, bool) {
+	param := func(i int) uint8 {
+		if i < len(params) {
+			return uint8(params[i])
+		}
+		return 0
+	}
+	if len(params) == 0 {
+		return buffer.Color{}, 0, false
+	}
+	switch params[0] {
+	case 5:
+		return buffer.IndexedColor(param(1)), min(2, len(params)), true
+	case 2:
+		return buffer.NewColor(param(1), param(2), param(3)), min(4, len(params)), true
+	default:
+		return buffer.Color{}, 0, false
+	}
+}
+
+// sgrString encodes the brush as SGR parameters that set it from the reset state (e.g. 0;1;38;5;100)
+func sgrString(br buffer.Brush) string {
+	params := []string{"0"}
+	add := func(set bool, ps string) {
+		if set {
+			params = append(params, ps)
+		}
+	}
+	add(br.Bold, "1")
+	add(br.Underline, "4")
+	add(br.Blink, "5")
+	add(br.Invert, "7")
+	params = appendColor(params, br.FG, 30, 90, 38)
+	params = appendColor(params, br.BG, 40, 100, 48)
+	return strings.Join(params, ";")
+}
+
+// appendColor appends the SGR parameters of the color, the basic, bright and extended are the first parameters for the color
+func appendColor(params []string, c buffer.Color, basic, bright, extended int) []string {
+	switch {
+	case c.Type == buffer.ColorIndexed && c.Index < 8:
+		return append(params, strconv.Itoa(basic+int(c.Index)))
+	case c.Type == buffer.ColorIndexed && c.Index < 16:
+		return append(params, strconv.Itoa(bright+int(c.Index)-8))
+	case c.Type == buffer.ColorIndexed:
+		return append(params, strconv.Itoa(extended), "5", strconv.Itoa(int(c.Index)))
+	case c.Type == buffer.ColorRGB:
+		return append(params, strconv.Itoa(extended), "2", strconv.Itoa(int(c.R)), strconv.Itoa(int(c.G)), strconv.Itoa(int(c.B)))
+	default:
+		return params
+	}
+}
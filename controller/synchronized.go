@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/viktomas/gritty/buffer"
+)
+
+// synchronizedUpdateTimeout is the longest time we hold back rendering,
+// without it, a program that crashed during the synchronized update would freeze the screen
+var synchronizedUpdateTimeout = 1 * time.Second
+
+// updateSynchronizedOutput freezes the rendered screen when the program starts a synchronized update (mode 2026)
+// and unfreezes it when the update ends
+func (c *Controller) updateSynchronizedOutput() {
+	syncing := c.buffer.SynchronizedOutput()
+	switch {
+	case syncing && c.synchronized == nil:
+		c.synchronized = c.buffer.Clone()
+		c.syncGeneration++
+		generation := c.syncGeneration
+		c.syncTimer = time.AfterFunc(synchronizedUpdateTimeout, func() {
+			c.synchronizedUpdateTimedOut(generation)
+		})
+	case !syncing && c.synchronized != nil:
+		c.synchronized = nil
+		c.syncTimer.Stop()
+	}
+}
+
+// synchronizedUpdateTimedOut ends the synchronized update if it's still the same update that started the timer
+func (c *Controller) synchronizedUpdateTimedOut(generation int) {
+	c.mu.Lock()
+	if c.synchronized == nil || c.syncGeneration != generation {
+		c.mu.Unlock()
+		return
+	}
+	c.buffer.SetSynchronizedOutput(false)
+	c.synchronized = nil
+	c.mu.Unlock()
+	c.requestRender()
+}
+
+// renderHeld returns true during a synchronized update
+func (c *Controller) renderHeld() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.synchronized != nil
+}
+
+// screen returns the buffer that the GUI renders, during a synchronized update it's the state from the update start
+func (c *Controller) screen() *buffer.Buffer {
+	if c.synchronized != nil {
+		return c.synchronized
+	}
+	return c.buffer
+}
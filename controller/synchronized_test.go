@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSynchronizedOutput(t *testing.T) {
+	screenText := func(c *Controller) string {
+		var text []rune
+		for _, r := range c.Runes()[:3] {
+			text = append(text, r.R)
+		}
+		return string(text)
+	}
+
+	t.Run("renders the screen from the start of the update until it ends", func(t *testing.T) {
+		c, _ := makePipeController(t)
+		handleInput(c, "a\x1b[?2026hbc")
+		if c.renderHeld() != true || screenText(c) != "a  " {
+			t.Fatalf("the screen should be frozen during the update, but it is %q", screenText(c))
+		}
+		handleInput(c, "\x1b[?2026l")
+		if c.renderHeld() || screenText(c) != "abc" {
+			t.Fatalf("the screen should show the update after it ends, but it is %q", screenText(c))
+		}
+	})
+
+	t.Run("ends the update after a timeout", func(t *testing.T) {
+		defer func(timeout time.Duration) { synchronizedUpdateTimeout = timeout }(synchronizedUpdateTimeout)
+		synchronizedUpdateTimeout = time.Millisecond
+		c, _ := makePipeController(t)
+		c.dirty = make(chan struct{}, 1)
+		handleInput(c, "\x1b[?2026ha")
+		select {
+		case <-c.dirty:
+		case <-time.After(time.Second):
+			t.Fatalf("the controller should have asked for rendering after the timeout")
+		}
+		if c.renderHeld() || screenText(c) != "a  " {
+			t.Fatalf("the screen should show the update after the timeout, but it is %q", screenText(c))
+		}
+	})
+
+	t.Run("reports the mode", func(t *testing.T) {
+		c, r := makePipeController(t)
+		handleInput(c, "\x1b[?2026$p\x1b[?2026h\x1b[?2026$p\x1b[?1234$p\x1b[3$p\x1b[4h\x1b[4$p")
+		expected := "\x1b[?2026;2$y\x1b[?2026;1$y\x1b[?1234;0$y\x1b[3;0$y\x1b[4;1$y"
+		if reply := readReply(t, c, r); reply != expected {
+			t.Fatalf("the reply should have been %q, but was %q", expected, reply)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gioui.org/io/key"
+	"github.com/viktomas/gritty/terminfo"
+)
+
+// terminfoParams are the parameters used to expand the parametrized capabilities,
+// capabilities that aren't listed are expanded with the parameters 3 and 5
+var terminfoParams = map[string][][]any{
+	"setaf": {{1}, {9}, {100}},
+	"setab": {{1}, {9}, {100}},
+	"Ss":    {{1}, {2}, {3}, {4}, {5}, {6}},
+	"Sync":  {{1}, {2}},
+	"Ms":    {{"c", "Z3JpdHR5"}},
+}
+
+// terminfoKeys are the keys that produce the key capabilities
+var terminfoKeys = map[string]string{
+	"kbs":   key.NameDeleteBackward,
+	"kcub1": key.NameLeftArrow,
+	"kcud1": key.NameDownArrow,
+	"kcuf1": key.NameRightArrow,
+	"kcuu1": key.NameUpArrow,
+}
+
+// TestTerminfoCapabilities checks that gritty handles every sequence that the gritty terminfo entry advertises
+func TestTerminfoCapabilities(t *testing.T) {
+	for _, tc := range terminfo.Capabilities() {
+		if tc.Kind != terminfo.String {
+			continue
+		}
+		switch {
+		// u8 is the format of the DA1 answer, it's not sent to the terminal
+		case tc.Name == "u8":
+		case strings.HasPrefix(tc.Name, "k"):
+			name, ok := terminfoKeys[tc.Name]
+			if !ok {
+				t.Errorf("%s: gritty doesn't send this key", tc.Name)
+				continue
+			}
+			if sent := string(keyToBytes(name, 0)); sent != tc.Value {
+				t.Errorf("%s: the key sends %q, but the capability is %q", tc.Name, sent, tc.Value)
+			}
+		default:
+			params, ok := terminfoParams[tc.Name]
+			if !ok {
+				params = [][]any{{3, 5}}
+			}
+			for _, p := range params {
+				seq, err := tparm(tc.Value, p...)
+				if err != nil {
+					t.Fatalf("%s: %v", tc.Name, err)
+				}
+				c, r := makePipeController(t)
+				if out := captureOutput(t, func() { handleInput(c, seq) }); out != "" {
+					t.Errorf("%s: gritty doesn't handle %q:\n%s", tc.Name, seq, out)
+				}
+				readReply(t, c, r)
+			}
+		}
+	}
+}
+
+// captureOutput returns everything that f printed to the standard output or logged
+func captureOutput(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer func() {
+		os.Stdout = stdout
+		log.SetOutput(os.Stderr)
+	}()
+	f()
+	w.Close()
+	printed, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(printed) + logged.String()
+}
+
+// tparm expands the parametrized terminfo string, it supports the % codes that the gritty entry uses
+// https://man7.org/linux/man-pages/man5/terminfo.5.html#Parameterized_Strings
+func tparm(s string, params ...any) (string, error) {
+	var out strings.Builder
+	var stack []any
+	push := func(v any) { stack = append(stack, v) }
+	pop := func() any {
+		if len(stack) == 0 {
+			return 0
+		}
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v
+	}
+	popInt := func() int {
+		n, _ := pop().(int)
+		return n
+	}
+	// skip moves i after the %e or %; that ends the current branch, elseStops says whether %e ends it
+	skip := func(i int, elseStops bool) int {
+		depth := 0
+		for ; i < len(s)-1; i++ {
+			if s[i] != '%' {
+				continue
+			}
+			i++
+			switch s[i] {
+			case '?':
+				depth++
+			case ';':
+				if depth == 0 {
+					return i
+				}
+				depth--
+			case 'e':
+				if depth == 0 && elseStops {
+					return i
+				}
+			}
+		}
+		return i
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			out.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("incomplete %% code in %q", s)
+		}
+		switch c := s[i]; c {
+		case '%':
+			out.WriteByte('%')
+		case 'd':
+			out.WriteString(strconv.Itoa(popInt()))
+		case 'c':
+			out.WriteByte(byte(popInt()))
+		case 's':
+			out.WriteString(fmt.Sprint(pop()))
+		case 'p':
+			i++
+			n := int(s[i] - '1')
+			if n < 0 || n >= len(params) {
+				return "", fmt.Errorf("missing parameter %c for %q", s[i], s)
+			}
+			push(params[n])
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			n, err := strconv.Atoi(s[i+1 : i+end])
+			if err != nil {
+				return "", fmt.Errorf("invalid constant in %q", s)
+			}
+			push(n)
+			i += end
+		case 'i':
+			for j := 0; j < 2 && j < len(params); j++ {
+				if n, ok := params[j].(int); ok {
+					params[j] = n + 1
+				}
+			}
+		case '+', '-', '*', '/', 'm', '=', '<', '>', 'A', 'O':
+			b, a := popInt(), popInt()
+			results := map[byte]int{'+': a + b, '-': a - b, '*': a * b, '=': boolInt(a == b), '<': boolInt(a < b), '>': boolInt(a > b), 'A': boolInt(a != 0 && b != 0), 'O': boolInt(a != 0 || b != 0)}
+			if b != 0 {
+				results['/'], results['m'] = a/b, a%b
+			}
+			push(results[c])
+		case '!':
+			push(boolInt(popInt() == 0))
+		case '?', ';':
+		case 't':
+			if popInt() == 0 {
+				i = skip(i+1, true)
+			}
+		case 'e':
+			// we get here only at the end of the branch that was taken
+			i = skip(i+1, false)
+		default:
+			return "", fmt.Errorf("unsupported %%%c in %q", c, s)
+		}
+	}
+	return out.String(), nil
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestTparm(t *testing.T) {
+	setaf, _ := terminfo.Lookup("setaf")
+	cup, _ := terminfo.Lookup("cup")
+	testCases := []struct {
+		value    string
+		params   []any
+		expected string
+	}{
+		{value: cup.Value, params: []any{3, 5}, expected: "\x1b[4;6H"},
+		{value: setaf.Value, params: []any{1}, expected: "\x1b[31m"},
+		{value: setaf.Value, params: []any{9}, expected: "\x1b[91m"},
+		{value: setaf.Value, params: []any{100}, expected: "\x1b[38;5;100m"},
+	}
+	for _, tc := range testCases {
+		if got, err := tparm(tc.value, tc.params...); err != nil || got != tc.expected {
+			t.Errorf("tparm(%q, %v) should be %q, got %q (error %v)", tc.value, tc.params, tc.expected, got, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ go 1.21.1
 
 require (
 	gioui.org v0.2.0
+	github.com/BurntSushi/toml v1.5.0
 	github.com/creack/pty v1.1.18
 	golang.org/x/image v0.5.0
 )
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"log"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"github.com/viktomas/gritty/buffer"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// maxCachedGlyphs limits the glyph cache, the cache starts over when it's full
+const maxCachedGlyphs = 4096
+
+// Grid renders the terminal screen as a grid of character cells. Each cell is placed
+// at col*cellWidth, row*cellHeight regardless of what the shaper does with the glyph,
+// so wide glyphs, missing glyphs and glyphs from fallback fonts can't shift the rest of the line.
+type Grid struct {
+	Shaper *text.Shaper
+	Font   font.Font
+	Size   unit.Sp
+	// Palette translates the character colors to RGB
+	Palette buffer.Palette
+	metrics gridMetrics
+	glyphs  map[glyphKey]shapedGlyph
+	// rows keep the painted rows between frames, cacheKey is what they were painted with
+	rows     []rowCache
+	cacheKey gridCacheKey
+}
+
+// rowCache is a painted row, it's replayed in the next frames until the row changes
+type rowCache struct {
+	ops  op.Ops
+	call op.CallOp
+	// valid is false if the row changed and has to be painted again
+	valid bool
+	// animated rows have blinking characters, cursor rows have the cursor, they are painted in every frame
+	animated bool
+	cursor   bool
+}
+
+// gridCacheKey is everything except the cells that changes how the rows look, all rows are painted when it changes
+type gridCacheKey struct {
+	rows, cols int
+	font       font.Font
+	metrics    gridMetrics
+	metric     unit.Metric
+	palette    buffer.Palette
+}
+
+// gridMetrics are the cell dimensions for one font size in pixels
+type gridMetrics struct {
+	ppem fixed.Int26_6
+	cell f32.Point
+	// ascent is the distance from the top of the cell to the baseline
+	ascent float32
+	// descent is the distance from the baseline to the bottom of the glyphs
+	descent float32
+}
+
+// glyphStyle is the part of the brush that changes the shape of the glyph
+type glyphStyle struct {
+	bold bool
+}
+
+type glyphKey struct {
+	r     rune
+	style glyphStyle
+}
+
+// shapedGlyph is a character shaped with its dot (start of the baseline) at 0,0
+type shapedGlyph struct {
+	path    clip.PathSpec
+	bitmaps op.CallOp
+	// empty glyphs (e.g. space) have nothing to paint
+	empty bool
+}
+
+// TextCursor describes how to draw the cursor over the grid of characters
+type TextCursor struct {
+	// Pos is the index of the character under the cursor
+	Pos   int
+	Style buffer.CursorStyle
+	// Visible is false if the program hid the cursor
+	Visible bool
+	// Focused is false when the window doesn't have focus, the cursor is then drawn hollow
+	Focused bool
+	Color   color.NRGBA
+}
+
+// CellSize returns the size of one character cell in pixels
+func (g *Grid) CellSize(gtx layout.Context) f32.Point {
+	return g.updateMetrics(gtx).cell
+}
+
+// updateMetrics measures the cell when the font size in pixels changes, the glyph cache is then emptied
+func (g *Grid) updateMetrics(gtx layout.Context) gridMetrics {
+	ppem := fixed.I(gtx.Sp(g.Size))
+	if ppem == g.metrics.ppem {
+		return g.metrics
+	}
+	g.Shaper.LayoutString(text.Parameters{Font: g.Font, PxPerEm: ppem}, "A")
+	gl, ok := g.Shaper.NextGlyph()
+	if !ok {
+		log.Println("ok is false for the next glyph")
+	}
+	g.metrics = gridMetrics{
+		ppem: ppem,
+		// TODO find out why the line height is higher than the glyph
+		cell:    f32.Pt(fixedToFloat(gl.Advance), fixedToFloat(gl.Ascent+gl.Descent+1<<6)),
+		ascent:  fixedToFloat(gl.Ascent),
+		descent: fixedToFloat(gl.Descent),
+	}
+	g.glyphs = map[glyphKey]shapedGlyph{}
+	return g.metrics
+}
+
+// glyph returns the shaped character from the cache, the character is shaped if it's not in the cache yet
+func (g *Grid) glyph(r rune, style glyphStyle) shapedGlyph {
+	key := glyphKey{r: r, style: style}
+	if sg, ok := g.glyphs[key]; ok {
+		return sg
+	}
+	f := g.Font
+	if style.bold {
+		f.Weight = font.Bold
+	}
+	g.Shaper.LayoutString(text.Parameters{Font: f, PxPerEm: g.metrics.ppem, MaxLines: 1}, string(r))
+	var glyphs []text.Glyph
+	for gl, ok := g.Shaper.NextGlyph(); ok; gl, ok = g.Shaper.NextGlyph() {
+		glyphs = append(glyphs, gl)
+	}
+	sg := shapedGlyph{empty: len(glyphs) == 0}
+	if !sg.empty {
+		sg.path = g.Shaper.Shape(glyphs)
+		sg.bitmaps = g.Shaper.Bitmaps(glyphs)
+	}
+	if len(g.glyphs) >= maxCachedGlyphs {
+		g.glyphs = map[glyphKey]shapedGlyph{}
+	}
+	g.glyphs[key] = sg
+	return sg
+}
+
+// Layout paints the cells row by row, cols is the number of cells in a row.
+// lineAttrs contain the size attribute for every row.
+// damage are the rows that changed since the last Layout, the other rows are replayed from the previous frame.
+// The rows with the cursor or blinking characters are painted in every frame.
+func (g *Grid) Layout(gtx layout.Context, cells []buffer.BrushedRune, cols int, lineAttrs []buffer.LineAttr, cursor TextCursor, damage []int) layout.Dimensions {
+	m := g.updateMetrics(gtx)
+	if cols == 0 {
+		return layout.Dimensions{}
+	}
+	rows := len(cells) / cols
+	g.invalidateRows(gridCacheKey{rows: rows, cols: cols, font: g.Font, metrics: m, metric: gtx.Metric, palette: g.Palette}, damage)
+	cursorRow := -1
+	if cursor.Visible {
+		cursorRow = cursor.Pos / cols
+	}
+	for row := 0; row < rows; row++ {
+		rc := &g.rows[row]
+		if !rc.valid || rc.animated || rc.cursor || row == cursorRow {
+			rc.ops.Reset()
+			macro := op.Record(&rc.ops)
+			rowGtx := gtx
+			rowGtx.Ops = &rc.ops
+			rc.animated = g.paintRow(rowGtx, cells[row*cols:(row+1)*cols], row, cols, lineAttr(lineAttrs, row), cursor)
+			rc.call = macro.Stop()
+			rc.valid, rc.cursor = true, row == cursorRow
+		}
+		rc.call.Add(gtx.Ops)
+	}
+	return layout.Dimensions{Size: gtx.Constraints.Constrain(image.Pt(int(float32(cols)*m.cell.X), int(float32(rows)*m.cell.Y)))}
+}
+
+// invalidateRows marks the damaged rows for painting, all rows are painted when the grid size, the font or the palette changes
+func (g *Grid) invalidateRows(key gridCacheKey, damage []int) {
+	if key != g.cacheKey {
+		g.cacheKey = key
+		g.rows = make([]rowCache, key.rows)
+		return
+	}
+	for _, row := range damage {
+		if row >= 0 && row < len(g.rows) {
+			g.rows[row].valid = false
+		}
+	}
+}
+
+// paintRow paints one row of cells, it returns true if the row has blinking characters
+// The backgrounds are painted first, so glyphs that overflow their cell are not covered by the neighbouring cell.
+func (g *Grid) paintRow(gtx layout.Context, cells []buffer.BrushedRune, row, cols int, attr buffer.LineAttr, cursor TextCursor) (blinking bool) {
+	m := g.metrics
+	bg := convertColor(g.Palette.BG)
+	rowCells := visibleCells(cells, attr)
+	cellRect := func(col int) clip.Rect { return m.cellRect(row, col, attr) }
+
+	// backgrounds, neighbouring cells with the same background are painted as one rectangle
+	for start := 0; start < len(rowCells); {
+		_, runBG := g.cellColors(rowCells[start])
+		end := start + 1
+		for end < len(rowCells) {
+			if _, nextBG := g.cellColors(rowCells[end]); nextBG != runBG {
+				break
+			}
+			end++
+		}
+		// the window is already painted with the default background
+		if runBG != bg {
+			paint.FillShape(gtx.Ops, runBG, clip.Rect{Min: cellRect(start).Min, Max: cellRect(end - 1).Max}.Op())
+		}
+		start = end
+	}
+
+	// double height rows show only one half of the glyph
+	rowClip := clip.Rect{Min: cellRect(0).Min, Max: cellRect(len(rowCells) - 1).Max}.Push(gtx.Ops)
+	for col, br := range rowCells {
+		blinking = blinking || br.Brush.Blink
+		fg, bg := g.cellColors(br)
+		// blinking characters disappear by having the same foreground and background
+		if fg != bg {
+			g.paintCell(gtx, br, fg, cellRect(col), attr)
+		}
+	}
+
+	// every cursor shape is painted over the cell, the focused block covers the whole cell,
+	// so the character is painted again on top of it with the cell background color
+	if pos := cursor.Pos - row*cols; cursor.Visible && pos >= 0 && pos < len(rowCells) && cursorBlinkOn(cursor) {
+		paintCursor(gtx, cellRect(pos), cursor.Color, cursor)
+		if g.blockCursor(cursor) {
+			_, bg := g.cellColors(rowCells[pos])
+			g.paintCell(gtx, rowCells[pos], bg, cellRect(pos), attr)
+		}
+	}
+	rowClip.Pop()
+	return blinking
+}
+
+// paintCell paints the underline and the character of the cell with the fg color
+func (g *Grid) paintCell(gtx layout.Context, br buffer.BrushedRune, fg color.NRGBA, rect clip.Rect, attr buffer.LineAttr) {
+	m := g.metrics
+	if br.Brush.Underline {
+		// the underline is in the middle between the baseline and the bottom of the line
+		y := rect.Min.Y + int(m.ascent+m.descent/2)
+		paint.FillShape(gtx.Ops, fg, clip.Rect{Min: image.Pt(rect.Min.X, y), Max: image.Pt(rect.Max.X, y+gtx.Dp(1))}.Op())
+	}
+	if br.R != ' ' {
+		g.paintGlyph(gtx, g.glyph(br.R, glyphStyle{bold: br.Brush.Bold}), fg, rect, attr)
+	}
+}
+
+// paintGlyph paints the glyph with its dot on the cell baseline
+// double width glyphs are scaled horizontally, double height glyphs are scaled in both directions
+// and moved so that the cell shows their top or bottom half
+func (g *Grid) paintGlyph(gtx layout.Context, sg shapedGlyph, fg color.NRGBA, cell clip.Rect, attr buffer.LineAttr) {
+	if sg.empty {
+		return
+	}
+	baseline := float32(cell.Min.Y) + g.metrics.ascent
+	scale := f32.Pt(1, 1)
+	switch attr {
+	case buffer.LineDoubleWidth:
+		scale = f32.Pt(2, 1)
+	case buffer.LineDoubleHeightTop:
+		scale, baseline = f32.Pt(2, 2), float32(cell.Min.Y)+2*g.metrics.ascent
+	case buffer.LineDoubleHeightBottom:
+		scale, baseline = f32.Pt(2, 2), float32(cell.Min.Y)+2*g.metrics.ascent-g.metrics.cell.Y
+	}
+	t := op.Affine(f32.Affine2D{}.Scale(f32.Point{}, scale).Offset(f32.Pt(float32(cell.Min.X), baseline))).Push(gtx.Ops)
+	outline := clip.Outline{Path: sg.path}.Op().Push(gtx.Ops)
+	paint.ColorOp{Color: fg}.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+	outline.Pop()
+	if sg.bitmaps != (op.CallOp{}) {
+		sg.bitmaps.Add(gtx.Ops)
+	}
+	t.Pop()
+}
+
+// cellRect returns the pixel rectangle of the cell, the cells on double width and double height rows are twice as wide
+func (m gridMetrics) cellRect(row, col int, attr buffer.LineAttr) clip.Rect {
+	width := m.cell.X
+	if attr != buffer.LineSingleWidth {
+		width *= 2
+	}
+	// rounding both edges makes neighbouring cells touch without gaps
+	return clip.Rect{
+		Min: image.Pt(round(float32(col)*width), round(float32(row)*m.cell.Y)),
+		Max: image.Pt(round(float32(col+1)*width), round(float32(row+1)*m.cell.Y)),
+	}
+}
+
+// cellColors returns the foreground and background color of the cell
+func (g *Grid) cellColors(br buffer.BrushedRune) (fg, bg color.NRGBA) {
+	fg = convertColor(g.Palette.FGColor(br.Brush.FG))
+	bg = convertColor(g.Palette.BGColor(br.Brush.BG))
+	if br.Brush.Invert {
+		fg, bg = bg, fg
+	}
+	// blinking characters disappear every other interval
+	if br.Brush.Blink && shouldBlinkInvert() {
+		fg = bg
+	}
+	return fg, bg
+}
+
+func (g *Grid) blockCursor(cursor TextCursor) bool {
+	return cursor.Focused && cursor.Style.Shape == buffer.CursorBlock
+}
+
+// visibleCells returns the cells that fit on the row, double width rows show only the left half of the cells
+func visibleCells(cells []buffer.BrushedRune, attr buffer.LineAttr) []buffer.BrushedRune {
+	if attr != buffer.LineSingleWidth {
+		return cells[:max(len(cells)/2, 1)]
+	}
+	return cells
+}
+
+func lineAttr(lineAttrs []buffer.LineAttr, row int) buffer.LineAttr {
+	if row < len(lineAttrs) {
+		return lineAttrs[row]
+	}
+	return buffer.LineSingleWidth
+}
+
+func round(f float32) int {
+	return int(f + 0.5)
+}
+
+func fixedToFloat(i fixed.Int26_6) float32 {
+	return float32(i) / 64.0
+}
+
+// shouldBlinkInvert alternates between true and false every 500ms
+// it's used for blinking the cursor and characters with the blink attribute
+func shouldBlinkInvert() bool {
+	currentTime := time.Now()
+	return (currentTime.UnixNano()/int64(time.Millisecond)/500)%2 == 0
+}
+
+func convertColor(c buffer.RGB) color.NRGBA {
+	return color.NRGBA{A: 0xff, R: c.R, G: c.G, B: c.B}
+}
+
+// cursorBlinkOn returns false when the blinking cursor should be hidden
+// the cursor doesn't blink when the window is not focused
+func cursorBlinkOn(cursor TextCursor) bool {
+	return !cursor.Style.Blinking || !cursor.Focused || shouldBlinkInvert()
+}
+
+// paintCursor draws the cursor shape over the character cell, the focused block fills the whole cell
+func paintCursor(gtx layout.Context, cell clip.Rect, c color.NRGBA, cursor TextCursor) {
+	thickness := gtx.Dp(2)
+	if !cursor.Focused {
+		// unfocused cursor is always a hollow block
+		paint.FillShape(gtx.Ops, c, clip.Stroke{Path: cell.Path(), Width: float32(gtx.Dp(1))}.Op())
+		return
+	}
+	switch cursor.Style.Shape {
+	case buffer.CursorUnderline:
+		cell.Min.Y = cell.Max.Y - thickness
+	case buffer.CursorBar:
+		cell.Max.X = cell.Min.X + thickness
+	}
+	paint.FillShape(gtx.Ops, c, cell.Op())
+}
@@ -3,34 +3,72 @@ package main
 import (
 	"fmt"
 	"image"
+	"image/color"
 	"log"
+	"math"
 	"os"
+	"os/exec"
+	"runtime"
 	"strings"
+	"text/template"
 	"time"
 
 	"gioui.org/app"
 	"gioui.org/f32"
 	"gioui.org/font"
 	"gioui.org/font/gofont"
+	"gioui.org/io/clipboard"
 	"gioui.org/io/key"
+	"gioui.org/io/pointer"
 	"gioui.org/io/system"
 	"gioui.org/layout"
 	"gioui.org/op"
+	"gioui.org/op/clip"
 	"gioui.org/op/paint"
 	"gioui.org/text"
 	"gioui.org/unit"
 	"github.com/viktomas/gritty/buffer"
+	"github.com/viktomas/gritty/config"
 	"github.com/viktomas/gritty/controller"
-	"golang.org/x/image/math/fixed"
 )
 
-const monoTypeface = "go mono, monospaced"
-const fontSize = 16
+const defaultTitle = "Gritty"
 
-func StartGui(shell string, controller *controller.Controller) {
+// titleTemplate renders the window title from titleData, it's configured with the title option
+var titleTemplate = template.Must(template.New("title").Parse(config.Default().Title))
+
+// titleData is available to the titleTemplate
+type titleData struct {
+	// Title is the title set by the program running in the terminal
+	Title string
+	c     *controller.Controller
+}
+
+// Cwd is the working directory of the shell, it's a method so that only templates using {{.Cwd}} look it up
+func (d titleData) Cwd() string {
+	if !d.c.Started() {
+		return ""
+	}
+	return d.c.WorkingDirectory()
+}
+
+var failedCommandColor = color.NRGBA{R: 0xdd, G: 0x33, B: 0x33, A: 0xff}
+
+// visualBellDuration is how long the window flashes when the program rings the bell
+const visualBellDuration = 100 * time.Millisecond
+
+// visualBellColor is a translucent foreground color painted over the whole window
+func visualBellColor(fg buffer.RGB) color.NRGBA {
+	c := convertColor(fg)
+	c.A = 0x40
+	return c
+}
+
+// StartGui opens the terminal window and runs the command in it, the config is reloaded when the config file changes
+func StartGui(opts options, cfg config.Config, command controller.Command, controller *controller.Controller) {
 	go func() {
-		w := app.NewWindow(app.Title("Gritty"))
-		if err := loop(w, shell, controller); err != nil {
+		w := app.NewWindow(app.Title(defaultTitle))
+		if err := loop(w, opts, cfg, command, controller); err != nil {
 			log.Fatal(err)
 		}
 		os.Exit(0)
@@ -38,9 +76,29 @@ func StartGui(shell string, controller *controller.Controller) {
 	app.Main()
 }
 
-func loop(w *app.Window, sh string, controller *controller.Controller) error {
+func loop(w *app.Window, opts options, cfg config.Config, command controller.Command, controller *controller.Controller) error {
 
 	shaper := text.NewShaper(text.WithCollection(gofont.Collection()))
+	grid := &Grid{Shaper: shaper}
+
+	// bindings are the gritty shortcuts
+	var bindings map[config.Key]config.Action
+	applyConfig := func(cfg config.Config) {
+		grid.Font = font.Font{Typeface: font.Typeface(cfg.Font.Family)}
+		grid.Size = unit.Sp(cfg.Font.Size)
+		bindings = cfg.Bindings()
+		linkOpener = cfg.Links.Opener
+		if len(linkOpener) == 0 {
+			linkOpener = defaultLinkOpener()
+		}
+		titleTemplate = template.Must(template.New("title").Parse(cfg.Title))
+		controller.SetOptions(cfg.ControllerOptions())
+	}
+	applyConfig(cfg)
+	// closed stops the goroutines that work for the window when the window closes
+	closed := make(chan struct{})
+	defer close(closed)
+	reloads := config.Watch(opts.configPath, opts.explicitConfig, closed)
 
 	var ops op.Ops
 
@@ -48,46 +106,107 @@ func loop(w *app.Window, sh string, controller *controller.Controller) error {
 
 	var windowSize image.Point
 
+	var windowTitle string
+
+	// cellSize is the size of one character cell in pixels
+	var cellSize f32.Point
+
+	// pointerPosition is the last known position of the mouse pointer, it's also the tag for pointer events
+	var pointerPosition f32.Point
+
+	// shortcutKeys are the pressed keys that ran a gritty shortcut, their releases don't go to the program either
+	shortcutKeys := map[string]bool{}
+
+	// underlinedLink is the link that was underlined in the last frame, its rows are painted again when the hover moves
+	var underlinedLink int
+
+	// scrollDistance accumulates scrolling that is too short to move the view by a whole line
+	var scrollDistance float32
+
+	// flashUntil is the time when the visual bell stops flashing
+	var flashUntil time.Time
+
+	// focused is true when the window has keyboard focus
+	var focused bool
+
+	// done is closed when the command exits, it's nil until the command starts
+	var done <-chan struct{}
+
 	cursorBlinkTicker := time.NewTicker(500 * time.Millisecond)
 
 	for {
 		select {
-		case <-controller.Done:
-			return nil
+		case <-done:
+			if !controller.Hold {
+				return nil
+			}
+			// the window stays open with the exit status until the user closes it
+			done = nil
+			w.Invalidate()
 		case <-cursorBlinkTicker.C:
 			w.Invalidate()
+		case r := <-reloads:
+			if r.Err != nil {
+				log.Printf("%v, keeping the previous config", r.Err)
+				break
+			}
+			applyConfig(r.Config)
+			// the font could have changed, so we measure the cells again
+			windowSize = image.Point{}
+			w.Invalidate()
 		case e := <-w.Events():
 			switch e := e.(type) {
 			case system.DestroyEvent:
 				return e.Err
 			case system.FrameEvent:
 				gtx := layout.NewContext(&ops, e)
-				// paint the whole window with the background color
-				// FIXME: This is a temporary heck, the ideal solution would be to
-				// shrink the window to the exact character grid after each resize
-				// (with some debouncing)
-				paint.ColorOp{Color: convertColor(buffer.DefaultBG)}.Add(gtx.Ops)
-				paint.PaintOp{}.Add(gtx.Ops)
-
+				if title := renderTitle(controller, opts.title); title != windowTitle {
+					windowTitle = title
+					w.Option(app.Title(title))
+				}
 				if e.Size != windowSize {
 					windowSize = e.Size // make sure this code doesn't run until we resized again
-					bufferSize := getBufferSize(gtx, fontSize, e.Size, shaper)
+					cellSize = grid.CellSize(gtx)
+					bufferSize := getBufferSize(cellSize, e.Size)
 					if !controller.Started() {
-
-						var err error
-						err = controller.Start(sh, bufferSize.Cols, bufferSize.Rows)
+						if opts.geometry != (buffer.BufferSize{}) {
+							// the program starts with the requested size even before the window resizes
+							bufferSize = opts.geometry
+							w.Option(app.Size(
+								unit.Dp(float32(bufferSize.Cols)*cellSize.X/gtx.Metric.PxPerDp),
+								unit.Dp(float32(bufferSize.Rows)*cellSize.Y/gtx.Metric.PxPerDp),
+							))
+						}
+						err := controller.Start(command, bufferSize.Cols, bufferSize.Rows)
 						if err != nil {
 							log.Fatalf("can't initialize PTY controller %v", err)
 						}
+						done = controller.Done
 						go func() {
-							for range controller.Render() {
-								w.Invalidate()
+							for {
+								select {
+								case _, ok := <-controller.Render():
+									if !ok {
+										return
+									}
+									w.Invalidate()
+								case <-closed:
+									return
+								}
 							}
 						}()
 					} else {
 						controller.Resize(bufferSize.Cols, bufferSize.Rows)
 					}
 				}
+				// paint the whole window with the background color
+				// FIXME: This is a temporary heck, the ideal solution would be to
+				// shrink the window to the exact character grid after each resize
+				// (with some debouncing)
+				palette := controller.Palette()
+				paint.ColorOp{Color: convertColor(palette.BG)}.Add(gtx.Ops)
+				paint.PaintOp{}.Add(gtx.Ops)
+
 				// keep the focus, since only one thing can
 				key.FocusOp{Tag: &location}.Add(&ops)
 				// register tag &location as reading input
@@ -98,33 +217,125 @@ func loop(w *app.Window, sh string, controller *controller.Controller) error {
 
 				// Capture and handle keyboard input
 				for _, ev := range gtx.Events(&location) {
-					if ke, ok := ev.(key.Event); ok {
-						if ke.State == key.Press {
-							controller.KeyPressed(ke.Name, ke.Modifiers)
+					switch ev := ev.(type) {
+					case key.Event:
+						// modifiers alone don't produce any input, they'd only bring the view back from the scrollback before a Ctrl+click
+						if isModifierKey(ev.Name) {
+							break
+						}
+						if ev.State == key.Release {
+							if shortcutKeys[ev.Name] {
+								delete(shortcutKeys, ev.Name)
+								break
+							}
+							controller.KeyReleased(ev.Name, ev.Modifiers)
+							break
+						}
+						if handleShortcut(bindings[configKey(ev)], controller, gtx.Ops, opts) {
+							shortcutKeys[ev.Name] = true
+							w.Invalidate()
+							break
+						}
+						delete(shortcutKeys, ev.Name)
+						controller.KeyPressed(ev.Name, ev.Modifiers)
+					case key.FocusEvent:
+						focused = ev.Focus
+						// the cursor is drawn hollow without focus and programs that asked for it get CSI I or CSI O
+						controller.SetFocused(focused)
+					case clipboard.Event:
+						controller.SendClipboard(ev.Text)
+					}
+				}
+				if controller.TakeBell() {
+					if controller.Bell.Visual {
+						flashUntil = time.Now().Add(visualBellDuration)
+						time.AfterFunc(visualBellDuration, w.Invalidate)
+					}
+					// Gio can't set the urgency hint, but window managers usually turn raise requests from unfocused windows into one
+					if controller.Bell.Urgent && !focused {
+						w.Perform(system.ActionRaise)
+					}
+				}
+				// programs copy and paste with OSC 52
+				if text, ok := controller.TakeClipboard(); ok {
+					clipboard.WriteOp{Text: text}.Add(gtx.Ops)
+				}
+				if controller.ClipboardReadRequested() {
+					clipboard.ReadOp{Tag: &location}.Add(gtx.Ops)
+				}
+				// the damage is taken before the runes, so changes made in between are painted now and again in the next frame
+				damage := controller.TakeDamage()
+				runes := controller.Runes()
+				cols := controller.Size().Cols
+				for _, ev := range gtx.Events(&pointerPosition) {
+					ev, ok := ev.(pointer.Event)
+					if !ok {
+						continue
+					}
+					switch ev.Type {
+					case pointer.Move:
+						pointerPosition = ev.Position
+					case pointer.Press:
+						pointerPosition = ev.Position
+						if ev.Modifiers.Contain(key.ModCtrl) {
+							if l, ok := controller.Link(linkAt(runes, cols, cellSize, pointerPosition)); ok {
+								openLink(l.URI)
+							}
+						}
+					case pointer.Scroll:
+						scrollDistance += ev.Scroll.Y
+						lines := int(scrollDistance / cellSize.Y)
+						scrollDistance -= float32(lines) * cellSize.Y
+						if lines != 0 {
+							controller.ScrollView(-lines)
+							damage = append(damage, controller.TakeDamage()...)
+							runes = controller.Runes()
 						}
 					}
 				}
+				// the hovered link is underlined in its whole length
+				hoveredLink := linkAt(runes, cols, cellSize, pointerPosition)
+				for i := range runes {
+					if runes[i].Link == 0 {
+						continue
+					}
+					if runes[i].Link == hoveredLink {
+						runes[i].Brush.Underline = true
+					}
+					if hoveredLink != underlinedLink && (runes[i].Link == hoveredLink || runes[i].Link == underlinedLink) {
+						damage = append(damage, i/cols)
+					}
+				}
+				underlinedLink = hoveredLink
+				area := clip.Rect{Max: e.Size}.Push(gtx.Ops)
+				pointer.InputOp{
+					Tag:          &pointerPosition,
+					Types:        pointer.Move | pointer.Press | pointer.Scroll,
+					ScrollBounds: image.Rect(0, math.MinInt32, 0, math.MaxInt32),
+				}.Add(gtx.Ops)
+				if hoveredLink != 0 {
+					pointer.CursorPointer.Add(gtx.Ops)
+				}
+				area.Pop()
+
 				// inset := layout.UniformInset(5)
 				layout.Flex{Axis: layout.Vertical}.Layout(gtx,
 					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-						params := text.Parameters{
-							Font: font.Font{
-								Typeface: font.Typeface(monoTypeface),
-							},
-							PxPerEm: fixed.I(gtx.Sp(fontSize)),
-						}
-						shaper.LayoutString(params, "Hello")
-						l := Label{
-							// we don't put new lines at the end of the line
-							// so we need the layout mechanism to use a policy
-							// to maximize the number of characters printed per line
-							WrapPolicy: text.WrapGraphemes,
+						grid.Palette = palette
+						cursor, cursorStyle, cursorVisible := controller.Cursor()
+						textCursor := TextCursor{
+							Pos:     cursor.Y*cols + cursor.X,
+							Style:   cursorStyle,
+							Visible: cursorVisible,
+							Focused: focused,
+							Color:   convertColor(palette.Cursor),
 						}
-						font := font.Font{
-							Typeface: font.Typeface(monoTypeface),
+						dims := grid.Layout(gtx, runes, cols, controller.LineAttrs(), textCursor, damage)
+						paintFailedCommands(gtx, controller.LineMarks(), cellSize)
+						if time.Now().Before(flashUntil) {
+							paint.FillShape(gtx.Ops, visualBellColor(palette.FG), clip.Rect{Max: gtx.Constraints.Max}.Op())
 						}
-
-						return l.Layout(gtx, shaper, font, fontSize, controller.Runes())
+						return dims
 						// screenSize := getScreenSize(gtx, fontSize, e.Size, th)
 						// return l.Layout(gtx, th.Shaper, font, fontSize, generateTestContent(screenSize.rows, screenSize.cols))
 					}),
@@ -170,26 +381,163 @@ func generateTestContent(rows, cols int) []buffer.BrushedRune {
 	return screen
 }
 
-// div divides two int26_6 numberes
-func div(a, b fixed.Int26_6) fixed.Int26_6 {
-	return (a * (1 << 6)) / b
+func getBufferSize(cellSize f32.Point, windowSize image.Point) buffer.BufferSize {
+	cols := int(float32(windowSize.X) / cellSize.X)
+	rows := int(float32(windowSize.Y) / cellSize.Y)
+	return buffer.BufferSize{Rows: rows, Cols: cols}
 }
 
-func getBufferSize(gtx layout.Context, textSize unit.Sp, windowSize image.Point, sh *text.Shaper) buffer.BufferSize {
-	params := text.Parameters{
-		Font: font.Font{
-			Typeface: font.Typeface(monoTypeface),
-		},
-		PxPerEm: fixed.I(gtx.Sp(fontSize)),
+// handleShortcut runs the gritty action bound to the key, it returns false if the key isn't a shortcut and should go to the program
+func handleShortcut(action config.Action, c *controller.Controller, ops *op.Ops, opts options) bool {
+	switch action {
+	case config.ActionScrollPageUp:
+		c.ScrollView(c.Size().Rows / 2)
+	case config.ActionScrollPageDown:
+		c.ScrollView(-c.Size().Rows / 2)
+	case config.ActionPreviousPrompt:
+		c.PreviousPrompt()
+	case config.ActionNextPrompt:
+		c.NextPrompt()
+	case config.ActionCopyLastOutput:
+		clipboard.WriteOp{Text: c.LastCommandOutput()}.Add(ops)
+	case config.ActionNewWindow:
+		openNewWindow(c.WorkingDirectory(), opts)
+	default:
+		return false
 	}
-	sh.Layout(params, strings.NewReader("A"))
-	g, ok := sh.NextGlyph()
-	if !ok {
-		log.Println("ok is false for the next glyph")
+	return true
+}
+
+// configKeyNames translates the Gio key names to the key names used in the config
+var configKeyNames = map[string]string{
+	key.NameUpArrow:        "Up",
+	key.NameDownArrow:      "Down",
+	key.NameLeftArrow:      "Left",
+	key.NameRightArrow:     "Right",
+	key.NamePageUp:         "PageUp",
+	key.NamePageDown:       "PageDown",
+	key.NameHome:           "Home",
+	key.NameEnd:            "End",
+	key.NameDeleteForward:  "Delete",
+	key.NameDeleteBackward: "Backspace",
+	key.NameReturn:         "Enter",
+	key.NameEnter:          "Enter",
+	key.NameEscape:         "Escape",
+}
+
+// configKey translates the key event to the key used in the key bindings
+func configKey(ev key.Event) config.Key {
+	k := config.Key{Name: ev.Name}
+	if name, ok := configKeyNames[ev.Name]; ok {
+		k.Name = name
 	}
-	glyphWidth := g.Advance
-	glyphHeight := g.Ascent + g.Descent + 1<<6 // TODO find out why the line height is higher than the glyph
-	cols := div(fixed.I(windowSize.X), glyphWidth).Floor()
-	rows := div(fixed.I(windowSize.Y), glyphHeight).Floor()
-	return buffer.BufferSize{Rows: rows, Cols: cols}
+	mods := map[key.Modifiers]config.Modifiers{
+		key.ModCtrl:  config.ModCtrl,
+		key.ModShift: config.ModShift,
+		key.ModAlt:   config.ModAlt,
+		key.ModSuper: config.ModSuper,
+	}
+	for gioMod, mod := range mods {
+		if ev.Modifiers.Contain(gioMod) {
+			k.Mods |= mod
+		}
+	}
+	return k
+}
+
+// paintFailedCommands paints a red marker on the left edge of prompts whose command exited with a non-zero code
+func paintFailedCommands(gtx layout.Context, marks []buffer.LineMarks, cellSize f32.Point) {
+	width := gtx.Dp(2)
+	for row, m := range marks {
+		if m&buffer.MarkFailed == 0 {
+			continue
+		}
+		top := int(float32(row) * cellSize.Y)
+		bottom := int(float32(row+1) * cellSize.Y)
+		paint.FillShape(gtx.Ops, failedCommandColor, clip.Rect{Min: image.Pt(0, top), Max: image.Pt(width, bottom)}.Op())
+	}
+}
+
+func isModifierKey(name string) bool {
+	switch name {
+	case key.NameCtrl, key.NameShift, key.NameAlt, key.NameSuper, key.NameCommand:
+		return true
+	}
+	return false
+}
+
+// renderTitle returns the window title rendered with the titleTemplate, the fixed title (--title) replaces the template
+func renderTitle(c *controller.Controller, fixed string) string {
+	if fixed != "" {
+		return fixed
+	}
+	data := titleData{Title: c.Title(), c: c}
+	var sb strings.Builder
+	if err := titleTemplate.Execute(&sb, data); err != nil {
+		log.Printf("rendering the window title failed: %v", err)
+		return defaultTitle
+	}
+	return sb.String()
+}
+
+// openNewWindow starts a new gritty process with the shell in the dir directory, empty dir means the current directory
+// the new window uses the same config file, the default config file is passed on implicitly so it doesn't have to exist
+func openNewWindow(dir string, opts options) {
+	executable, err := os.Executable()
+	if err != nil {
+		log.Printf("opening a new window failed: %v", err)
+		return
+	}
+	var args []string
+	if opts.explicitConfig {
+		args = append(args, "--config", opts.configPath)
+	}
+	cmd := exec.Command(executable, args...)
+	cmd.Dir = dir
+	if err := cmd.Start(); err != nil {
+		log.Printf("opening a new window failed: %v", err)
+		return
+	}
+	go cmd.Wait()
+}
+
+// linkAt returns the link reference of the character under the pointer position, 0 means no link
+func linkAt(runes []buffer.BrushedRune, cols int, cellSize f32.Point, pos f32.Point) int {
+	if cellSize.X == 0 || cellSize.Y == 0 || pos.X < 0 || pos.Y < 0 {
+		return 0
+	}
+	col := int(pos.X / cellSize.X)
+	i := int(pos.Y/cellSize.Y)*cols + col
+	if col >= cols || i >= len(runes) {
+		return 0
+	}
+	return runes[i].Link
+}
+
+// linkOpener is the command that opens hyperlinks, the URI is passed to it as the last argument
+var linkOpener = defaultLinkOpener()
+
+func defaultLinkOpener() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"open"}
+	case "windows":
+		return []string{"rundll32", "url.dll,FileProtocolHandler"}
+	default:
+		return []string{"xdg-open"}
+	}
+}
+
+// openLink opens the URI with the linkOpener without waiting for the opener to finish
+func openLink(uri string) {
+	if len(linkOpener) == 0 {
+		return
+	}
+	args := append(linkOpener[1:len(linkOpener):len(linkOpener)], uri)
+	cmd := exec.Command(linkOpener[0], args...)
+	if err := cmd.Start(); err != nil {
+		log.Printf("opening link %q failed: %v", uri, err)
+		return
+	}
+	go cmd.Wait()
 }
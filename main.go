@@ -1,9 +1,140 @@
 package main
 
-import "github.com/viktomas/gritty/controller"
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/viktomas/gritty/buffer"
+	"github.com/viktomas/gritty/config"
+	"github.com/viktomas/gritty/controller"
+	"github.com/viktomas/gritty/terminfo"
+)
+
+// options are set with the command line flags
+type options struct {
+	configPath string
+	// explicitConfig is true if the user chose the config file with --config, then the file has to exist
+	explicitConfig bool
+	// command replaces the shell if it's not empty
+	command          []string
+	workingDirectory string
+	// geometry is the initial size of the terminal, zero size fits the terminal into the default window
+	geometry buffer.BufferSize
+	// title replaces the title set by the program
+	title string
+	hold  bool
+	// installTerminfo installs the gritty terminfo entry instead of opening the window
+	installTerminfo bool
+}
 
 func main() {
-	shell := "/bin/sh"
-	controller := &controller.Controller{}
-	StartGui(shell, controller)
+	opts, err := parseFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if opts.installTerminfo {
+		dir, err := terminfo.Install()
+		if err != nil {
+			log.Fatalf("can't install the terminfo entry: %v", err)
+		}
+		fmt.Printf("the %s terminfo entry is installed in %s\n", terminfo.Name, dir)
+		return
+	}
+	load := config.Load
+	if opts.explicitConfig {
+		load = config.LoadFile
+	}
+	cfg, err := load(opts.configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	command := controller.Command{
+		Path:  cfg.Shell.Program,
+		Args:  cfg.Shell.Args,
+		Dir:   opts.workingDirectory,
+		Env:   cfg.Environ(),
+		Unset: cfg.UnsetEnv,
+	}
+	if len(opts.command) > 0 {
+		command.Path, command.Args = opts.command[0], opts.command[1:]
+	}
+	controller := &controller.Controller{
+		Notifier: defaultNotifier(),
+		Hold:     opts.hold,
+	}
+	StartGui(opts, cfg, command, controller)
+}
+
+func parseFlags(args []string) (options, error) {
+	var opts options
+	fs := flag.NewFlagSet("gritty", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gritty [flags] [-e command [args...]]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	execute := fs.Bool("e", false, "run the command that follows (all remaining arguments) instead of the shell")
+	fs.StringVar(&opts.workingDirectory, "working-directory", "", "start the shell or the command in the `directory`")
+	geometry := fs.String("geometry", "", "the initial size of the terminal in `COLSxROWS` (e.g. 80x24)")
+	fs.StringVar(&opts.title, "title", "", "the window title, it replaces the title set by the program")
+	fs.BoolVar(&opts.hold, "hold", false, "keep the window open after the command exits and show its exit status")
+	defaultConfig, err := config.Path()
+	if err != nil {
+		log.Println(err)
+	}
+	fs.StringVar(&opts.configPath, "config", defaultConfig, "the config file `path`")
+	fs.BoolVar(&opts.installTerminfo, "install-terminfo", false, "compile the gritty terminfo entry with tic, install it into ~/.terminfo and exit")
+	if err := fs.Parse(args); err != nil {
+		return options{}, err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		opts.explicitConfig = opts.explicitConfig || f.Name == "config"
+	})
+	if *execute {
+		if fs.NArg() == 0 {
+			return options{}, errors.New("-e needs the command to run")
+		}
+		opts.command = fs.Args()
+	} else if fs.NArg() > 0 {
+		return options{}, fmt.Errorf("unexpected arguments %q, use -e to run a command", fs.Args())
+	}
+	if *geometry != "" {
+		if opts.geometry, err = parseGeometry(*geometry); err != nil {
+			return options{}, err
+		}
+	}
+	if opts.workingDirectory != "" {
+		if fi, err := os.Stat(opts.workingDirectory); err != nil || !fi.IsDir() {
+			return options{}, fmt.Errorf("--working-directory %q is not a directory", opts.workingDirectory)
+		}
+	}
+	return opts, nil
+}
+
+// parseGeometry parses the COLSxROWS terminal size
+func parseGeometry(s string) (buffer.BufferSize, error) {
+	cols, rows, ok := strings.Cut(s, "x")
+	c, colsErr := strconv.Atoi(cols)
+	r, rowsErr := strconv.Atoi(rows)
+	if !ok || colsErr != nil || rowsErr != nil || c < 1 || r < 1 {
+		return buffer.BufferSize{}, fmt.Errorf("invalid --geometry %q, expected COLSxROWS (e.g. 80x24)", s)
+	}
+	return buffer.BufferSize{Cols: c, Rows: r}, nil
+}
+
+// defaultNotifier uses notify-send on Linux, other systems don't show notifications
+func defaultNotifier() controller.Notifier {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	return controller.CommandNotifier{Command: []string{"notify-send", "--app-name=gritty"}}
 }
@@ -6,8 +6,21 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
+// maxDCSLength limits the DCS data string, the rest of the data is dropped
+const maxDCSLength = 4096
+
+// maxOSCLength limits the OSC string, longer OSC is dropped. It fits OSC 52 with 1 MiB of base64 encoded clipboard text.
+const maxOSCLength = 2 << 20
+
+// maxParamsLength limits the parameters and the intermediate characters, the rest of them is dropped
+const maxParamsLength = 256
+
+// maxRawLength limits the bytes kept in Operation.Raw, it fits the longest DCS with its introducer, parameters and terminator
+const maxRawLength = maxDCSLength + 2*maxParamsLength + 8
+
 type Parser struct {
 	state        parserState
 	privateFlag  int
@@ -15,6 +28,12 @@ type Parser struct {
 	intermediate []byte
 	params       []byte
 	osc          []byte
+	dcs          []byte
+	dcsFinal     byte
+	// oscTooLong is true if the OSC string didn't fit into maxOSCLength, such OSC is dropped
+	oscTooLong bool
+	// utf8 holds the bytes of a UTF-8 encoded character that isn't complete yet
+	utf8 []byte
 }
 
 type OperationType uint32
@@ -25,6 +44,8 @@ type Operation struct {
 	Intermediate string
 	Params       []int
 	Osc          string
+	// Data is the DCS data string between the final character and the string terminator
+	Data string
 	// Raw is the sequence of bytes that the parser processed to make this operation
 	Raw []byte
 }
@@ -56,6 +77,8 @@ func (o Operation) String() string {
 		opString = fmt.Sprintf("ESC: %s %q", o.Intermediate, string(o.R))
 	case OpCSI:
 		opString = fmt.Sprintf("CSI: %s %v %q", o.Intermediate, o.Params, string(o.R))
+	case OpDCS:
+		opString = fmt.Sprintf("DCS: %s %v %q %q", o.Intermediate, o.Params, string(o.R), o.Data)
 	default:
 		log.Fatalln("Unknown operation type: ", o.T)
 		return ""
@@ -69,6 +92,7 @@ const (
 	OpESC
 	OpCSI
 	OpOSC
+	OpDCS
 )
 
 type parserState int
@@ -82,6 +106,11 @@ const (
 	sCSIIgnore
 	sCSIIntermediate
 	sOSC
+	sDCSEntry
+	sDCSParam
+	sDCSIntermediate
+	sDCSPassthrough
+	sDCSIgnore
 )
 
 func New() *Parser {
@@ -96,8 +125,8 @@ func (d *Parser) pExecute(b byte) Operation {
 	return op
 }
 
-func (d *Parser) pPrint(b byte) Operation {
-	op := Operation{T: OpPrint, R: rune(b), Raw: d.buf}
+func (d *Parser) pPrint(r rune) Operation {
+	op := Operation{T: OpPrint, R: r, Raw: d.buf}
 	d.buf = nil
 	return op
 }
@@ -108,7 +137,7 @@ func (d *Parser) escDispatch(b byte) Operation {
 	return op
 }
 
-func (d *Parser) csiDispatch(b byte) Operation {
+func (d *Parser) parseParams() []int {
 	var params []int
 	if len(d.params) > 0 {
 		stringNumbers := strings.Split(string(d.params), ";")
@@ -121,17 +150,56 @@ func (d *Parser) csiDispatch(b byte) Operation {
 			params = append(params, int(i))
 		}
 	}
-	op := Operation{T: OpCSI, R: rune(b), Params: params, Intermediate: string(d.intermediate), Raw: d.buf}
+	return params
+}
+
+func (d *Parser) csiDispatch(b byte) Operation {
+	op := Operation{T: OpCSI, R: rune(b), Params: d.parseParams(), Intermediate: string(d.intermediate), Raw: d.buf}
 	d.buf = nil
 	return op
 }
 
-func (d *Parser) oscDispatch() Operation {
-	op := Operation{T: OpOSC, Osc: string(d.osc), Raw: d.buf}
+// dcsHook remembers the DCS final character, the data string follows it
+func (d *Parser) dcsHook(b byte) {
+	d.dcs = nil
+	d.dcsFinal = b
+}
+
+// dcsDispatch returns the DCS operation when the string terminator ends the data string
+func (d *Parser) dcsDispatch() Operation {
+	op := Operation{T: OpDCS, R: rune(d.dcsFinal), Params: d.parseParams(), Intermediate: string(d.intermediate), Data: string(d.dcs), Raw: d.buf}
 	d.buf = nil
 	return op
 }
 
+// oscStart starts collecting a new OSC string
+func (d *Parser) oscStart() {
+	d.osc = nil
+	d.oscTooLong = false
+	d.state = sOSC
+}
+
+// oscPut adds the byte to the OSC string, the bytes over maxOSCLength aren't kept and the OSC is then dropped
+func (d *Parser) oscPut(b byte) {
+	if len(d.osc) < maxOSCLength {
+		d.osc = append(d.osc, b)
+	} else {
+		d.oscTooLong = true
+	}
+}
+
+// oscDispatch appends the OSC operation to the result, unless the OSC was too long
+func (d *Parser) oscDispatch(result []Operation) []Operation {
+	if d.oscTooLong {
+		log.Printf("dropping OSC longer than %d bytes", maxOSCLength)
+		d.buf = nil
+		return result
+	}
+	op := Operation{T: OpOSC, Osc: string(d.osc), Raw: d.buf}
+	d.buf = nil
+	return append(result, op)
+}
+
 func (d *Parser) clear() {
 	d.privateFlag = 0
 	d.intermediate = nil
@@ -139,11 +207,36 @@ func (d *Parser) clear() {
 }
 
 func (d *Parser) collect(b byte) {
-	d.intermediate = append(d.intermediate, b)
+	if len(d.intermediate) < maxParamsLength {
+		d.intermediate = append(d.intermediate, b)
+	}
 }
 
 func (d *Parser) param(b byte) {
-	d.params = append(d.params, b)
+	if len(d.params) < maxParamsLength {
+		d.params = append(d.params, b)
+	}
+}
+
+// printUTF8 collects the bytes of UTF-8 encoded characters in the ground state, it returns true if it used the byte.
+// It goes before the C1 control characters, because the UTF-8 continuation bytes (0x80-0xBF) include them.
+func (d *Parser) printUTF8(b byte, result []Operation) ([]Operation, bool) {
+	if len(d.utf8) > 0 && btw(b, 0x80, 0xbf) {
+		d.utf8 = append(d.utf8, b)
+		if utf8.FullRune(d.utf8) {
+			r, _ := utf8.DecodeRune(d.utf8)
+			d.utf8 = nil
+			result = append(result, d.pPrint(r))
+		}
+		return result, true
+	}
+	// a character that didn't get all its continuation bytes is dropped
+	d.utf8 = nil
+	if btw(b, 0xc2, 0xf4) {
+		d.utf8 = append(d.utf8, b)
+		return result, true
+	}
+	return result, false
 }
 
 // btw (between) returns true if b >= start && b <= end
@@ -171,13 +264,40 @@ func (d *Parser) Parse(p []byte) []Operation {
 	var result []Operation
 	for i := 0; i < len(p); i++ {
 		b := p[i]
-		d.buf = append(d.buf, b)
+		// long strings (OSC, DCS) are cut in the Raw
+		kept := len(d.buf) < maxRawLength
+		if kept {
+			d.buf = append(d.buf, b)
+		}
+		if d.state == sGround {
+			var printed bool
+			if result, printed = d.printUTF8(b, result); printed {
+				continue
+			}
+		}
 		// Anywhere
 		if b == 0x1b {
+			if d.state == sOSC {
+				// ESC \ (ST - String Terminator) ends the OSC, the ESC \ is then parsed as a standalone sequence
+				if kept {
+					d.buf = d.buf[:len(d.buf)-1]
+				}
+				result = d.oscDispatch(result)
+				d.buf = append(d.buf, b)
+			}
+			if d.state == sDCSPassthrough {
+				// same as OSC, ESC \ ends the DCS
+				if kept {
+					d.buf = d.buf[:len(d.buf)-1]
+				}
+				result = append(result, d.dcsDispatch())
+				d.buf = append(d.buf, b)
+			}
 			d.state = sEscape
 			d.clear()
 			continue
 		}
+		// 0x90 (8-bit DCS) isn't recognized, DCS starts only with ESC P
 		if b == 0x18 || b == 0x1a || btw(b, 0x80, 0x8F) || btw(b, 0x91, 0x97) || b == 0x99 || b == 0x9a {
 			d.state = sGround
 			result = append(result, d.pExecute(b))
@@ -185,8 +305,7 @@ func (d *Parser) Parse(p []byte) []Operation {
 
 		}
 		if b == 0x9D {
-			d.state = sOSC
-			d.osc = nil
+			d.oscStart()
 			continue
 		}
 		switch d.state {
@@ -195,7 +314,7 @@ func (d *Parser) Parse(p []byte) []Operation {
 				result = append(result, d.pExecute(b))
 			}
 			if b >= 0x20 && b <= 0x7f {
-				result = append(result, d.pPrint(b))
+				result = append(result, d.pPrint(rune(b)))
 			}
 		case sEscape:
 			if isControlChar(b) {
@@ -214,8 +333,11 @@ func (d *Parser) Parse(p []byte) []Operation {
 				d.state = sCSIEntry
 			}
 			if b == 0x5d {
-				d.osc = nil
-				d.state = sOSC
+				d.oscStart()
+			}
+			if b == 0x50 {
+				d.clear()
+				d.state = sDCSEntry
 			}
 			// 7f ignore
 		case sEscapeIntermediate:
@@ -246,6 +368,10 @@ func (d *Parser) Parse(p []byte) []Operation {
 				d.collect(b)
 				d.state = sCSIParam
 			}
+			if btw(b, 0x20, 0x2f) {
+				d.collect(b)
+				d.state = sCSIIntermediate
+			}
 			if b == 0x3a {
 				d.state = sCSIIgnore
 			}
@@ -297,15 +423,75 @@ func (d *Parser) Parse(p []byte) []Operation {
 				// ignore
 			}
 			if btw(b, 0x20, 0x7f) {
-				d.osc = append(d.osc, b)
+				d.oscPut(b)
 			}
 			// 0x07 is xterm non-ANSI variant of transition to ground
 			// taken from https://github.com/asciinema/avt/blob/main/src/vt.rs#L423C17-L423C74
 			if b == 0x07 || b == 0x9c {
-				result = append(result, d.oscDispatch())
+				result = d.oscDispatch(result)
+				d.state = sGround
+			}
+			if b == 0x9c {
+				d.state = sGround
+			}
+		case sDCSEntry:
+			// control characters are ignored in all DCS states except the passthrough
+			if btw(b, 0x30, 0x39) || b == 0x3b {
+				d.param(b)
+				d.state = sDCSParam
+			}
+			if btw(b, 0x3c, 0x3f) {
+				d.collect(b)
+				d.state = sDCSParam
+			}
+			if btw(b, 0x20, 0x2f) {
+				d.collect(b)
+				d.state = sDCSIntermediate
+			}
+			if btw(b, 0x40, 0x7e) {
+				d.dcsHook(b)
+				d.state = sDCSPassthrough
+			}
+			if b == 0x3a {
+				d.state = sDCSIgnore
+			}
+		case sDCSParam:
+			if btw(b, 0x30, 0x39) || b == 0x3b {
+				d.param(b)
+			}
+			if btw(b, 0x20, 0x2f) {
+				d.collect(b)
+				d.state = sDCSIntermediate
+			}
+			if btw(b, 0x40, 0x7e) {
+				d.dcsHook(b)
+				d.state = sDCSPassthrough
+			}
+			if b == 0x3a || btw(b, 0x3c, 0x3f) {
+				d.state = sDCSIgnore
+			}
+		case sDCSIntermediate:
+			if btw(b, 0x20, 0x2f) {
+				d.collect(b)
+			}
+			if btw(b, 0x40, 0x7e) {
+				d.dcsHook(b)
+				d.state = sDCSPassthrough
+			}
+			if btw(b, 0x30, 0x3f) {
+				d.state = sDCSIgnore
+			}
+		case sDCSPassthrough:
+			if b == 0x9c {
+				result = append(result, d.dcsDispatch())
 				d.state = sGround
+			} else if b != 0x7f && len(d.dcs) < maxDCSLength {
+				d.dcs = append(d.dcs, b)
 			}
+		case sDCSIgnore:
+			// everything is ignored until the string terminator
 			if b == 0x9c {
+				d.buf = nil
 				d.state = sGround
 			}
 		}
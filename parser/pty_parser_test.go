@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -82,6 +83,18 @@ func TestParseCSI(t *testing.T) {
 		)
 	})
 
+	t.Run("parse sequences with intermediate character and no params", func(t *testing.T) {
+		instructions := New().Parse([]byte("\x1b[!p"))
+		if len(instructions) != 1 {
+			t.Fatalf("The parser should have returned 1 instruction but returned %d", len(instructions))
+		}
+		compInst(
+			t,
+			Operation{T: OpCSI, R: 'p', Intermediate: "!"},
+			instructions[0],
+		)
+	})
+
 	t.Run("parse", func(t *testing.T) {
 		tests := []struct {
 			input    []byte
@@ -120,6 +133,107 @@ func TestParseCSI(t *testing.T) {
 	})
 }
 
+func TestParseOSC(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		input string
+	}{
+		{desc: "terminated by BEL", input: "\x1b]0;title\x07"},
+		{desc: "terminated by ST", input: "\x1b]0;title\x9c"},
+		{desc: "terminated by ESC \\", input: "\x1b]0;title\x1b\\"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			output := New().Parse([]byte(tc.input))
+			if len(output) == 0 {
+				t.Fatalf("the parser didn't return any operation")
+			}
+			if output[0].T != OpOSC || output[0].Osc != "0;title" {
+				t.Fatalf("the first operation should have been OSC with %q, but was %v", "0;title", output[0])
+			}
+		})
+	}
+
+	t.Run("drops OSC over the limit", func(t *testing.T) {
+		p := New()
+		p.Parse([]byte("\x1b]52;c;"))
+		chunk := []byte(strings.Repeat("A", 1<<16))
+		for i := 0; i < maxOSCLength/len(chunk)+1; i++ {
+			p.Parse(chunk)
+		}
+		if len(p.osc) > maxOSCLength || len(p.buf) > maxRawLength {
+			t.Fatalf("the parser should keep at most %d bytes of the OSC, but keeps %d (raw %d)", maxOSCLength, len(p.osc), len(p.buf))
+		}
+		output := p.Parse([]byte("\x07A"))
+		if len(output) != 1 || output[0].T != OpPrint || output[0].R != 'A' {
+			t.Fatalf("only the character after the OSC should have been printed, got %v", output)
+		}
+	})
+}
+
+func TestParseDCS(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		input    string
+		expected Operation
+	}{
+		{desc: "terminated by ESC \\", input: "\x1bP+q544e\x1b\\", expected: Operation{T: OpDCS, R: 'q', Intermediate: "+", Data: "544e"}},
+		{desc: "terminated by ST", input: "\x1bP$qm\x9c", expected: Operation{T: OpDCS, R: 'q', Intermediate: "$", Data: "m"}},
+		{desc: "with params", input: "\x1bP1;2|data\x9c", expected: Operation{T: OpDCS, R: '|', Params: []int{1, 2}, Data: "data"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			output := New().Parse([]byte(tc.input))
+			if len(output) == 0 {
+				t.Fatalf("the parser didn't return any operation")
+			}
+			compInst(t, tc.expected, output[0])
+			if output[0].Data != tc.expected.Data {
+				t.Fatalf("the DCS data should have been %q, but was %q", tc.expected.Data, output[0].Data)
+			}
+		})
+	}
+
+	t.Run("ignores invalid DCS until the string terminator", func(t *testing.T) {
+		output := New().Parse([]byte("\x1bP1:2qdata\x9cA"))
+		if len(output) != 1 || output[0].T != OpPrint || output[0].R != 'A' {
+			t.Fatalf("only the character after the DCS should have been printed, got %v", output)
+		}
+	})
+
+	t.Run("drops the data over the limit", func(t *testing.T) {
+		data := strings.Repeat("a", maxDCSLength+10)
+		output := New().Parse([]byte("\x1bP" + strings.Repeat("1;", maxParamsLength) + "|" + data + "\x1b\\"))
+		if len(output) == 0 || output[0].T != OpDCS || output[0].Data != data[:maxDCSLength] {
+			t.Fatalf("the DCS should have had the first %d bytes of the data, got %v", maxDCSLength, output)
+		}
+		if len(output[0].Params) > maxParamsLength || len(output[0].Raw) > maxRawLength {
+			t.Fatalf("the DCS should have at most %d params and %d raw bytes, but has %d and %d", maxParamsLength, maxRawLength, len(output[0].Params), len(output[0].Raw))
+		}
+	})
+}
+
+func TestParseUTF8(t *testing.T) {
+	// the Cyrillic А is D0 90, the 0x90 continuation byte must not start a DCS
+	input := "aéАѝ€😀b"
+	p := New()
+	var output []Operation
+	// the characters can be split between two reads from the PTY
+	for _, chunk := range []string{input[:4], input[4:]} {
+		output = append(output, p.Parse([]byte(chunk))...)
+	}
+	var printed []rune
+	for _, op := range output {
+		if op.T != OpPrint {
+			t.Fatalf("all operations should have been print, got %v", op)
+		}
+		printed = append(printed, op.R)
+	}
+	if string(printed) != input {
+		t.Fatalf("the parser should have printed %q, but printed %q", input, string(printed))
+	}
+}
+
 func TestParam(t *testing.T) {
 	testCases := []struct {
 		desc     string
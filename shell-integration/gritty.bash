@@ -0,0 +1,46 @@
+# gritty shell integration for bash, source it at the end of ~/.bashrc:
+#
+#   source /path/to/gritty/shell-integration/gritty.bash
+#
+# The script marks prompts, commands and their output with OSC 133 so gritty can
+# jump between prompts, copy the output of the last command and mark failed commands.
+
+if [[ -n "$GRITTY_SHELL_INTEGRATION" ]]; then
+  return
+fi
+GRITTY_SHELL_INTEGRATION=1
+
+__gritty_at_prompt=0
+__gritty_command_running=0
+
+# __gritty_precmd runs first in PROMPT_COMMAND, it reports the exit code of the finished command and marks the prompt start
+__gritty_precmd() {
+  local exit_code=$?
+  # commands in PROMPT_COMMAND aren't typed by the user
+  __gritty_at_prompt=0
+  if [[ $__gritty_command_running == 1 ]]; then
+    printf '\e]133;D;%s\a' "$exit_code"
+    __gritty_command_running=0
+  fi
+  printf '\e]133;A\a'
+  return $exit_code
+}
+
+# __gritty_prompt_ready runs last in PROMPT_COMMAND, the next command that runs is the one the user typed
+__gritty_prompt_ready() {
+  __gritty_at_prompt=1
+}
+
+# __gritty_preexec runs before every simple command (DEBUG trap), it marks the output start of the first command after the prompt
+__gritty_preexec() {
+  if [[ $__gritty_at_prompt == 0 || -n "$COMP_LINE" || "$BASH_COMMAND" == "__gritty_precmd" ]]; then
+    return
+  fi
+  __gritty_at_prompt=0
+  __gritty_command_running=1
+  printf '\e]133;C\a'
+}
+
+PROMPT_COMMAND="__gritty_precmd${PROMPT_COMMAND:+;$PROMPT_COMMAND};__gritty_prompt_ready"
+PS1="$PS1\[\e]133;B\a\]"
+trap '__gritty_preexec' DEBUG
@@ -0,0 +1,23 @@
+# gritty shell integration for fish, source it from ~/.config/fish/config.fish:
+#
+#   source /path/to/gritty/shell-integration/gritty.fish
+#
+# The script marks prompts, commands and their output with OSC 133 so gritty can
+# jump between prompts, copy the output of the last command and mark failed commands.
+
+if set -q GRITTY_SHELL_INTEGRATION
+    exit
+end
+set -g GRITTY_SHELL_INTEGRATION 1
+
+function __gritty_prompt --on-event fish_prompt
+    printf '\e]133;A\a'
+end
+
+function __gritty_preexec --on-event fish_preexec
+    printf '\e]133;C\a'
+end
+
+function __gritty_postexec --on-event fish_postexec
+    printf '\e]133;D;%s\a' $status
+end
@@ -0,0 +1,67 @@
+package terminfo
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Install compiles the gritty entry with tic and puts it into ~/.terminfo, it returns the directory
+func Install() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ".terminfo")
+	src, err := os.CreateTemp("", "gritty-*.terminfo")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(src.Name())
+	_, err = src.WriteString(Source)
+	if closeErr := src.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	// -x compiles the extended capabilities (e.g. Sync) as well
+	out, err := exec.Command("tic", "-x", "-o", dir, src.Name()).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("tic failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return dir, nil
+}
+
+// Installed returns true if ncurses can find the gritty entry in one of the terminfo directories
+func Installed() bool {
+	for _, dir := range searchPath() {
+		// the entries are in subdirectories named after the first letter, macOS uses its hex code
+		for _, sub := range []string{Name[:1], fmt.Sprintf("%02x", Name[0])} {
+			if _, err := os.Stat(filepath.Join(dir, sub, Name)); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// searchPath returns the directories where ncurses looks for the terminfo entries
+// https://man7.org/linux/man-pages/man5/terminfo.5.html#FETCHING_COMPILED_DESCRIPTIONS
+func searchPath() []string {
+	var dirs []string
+	if dir := os.Getenv("TERMINFO"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".terminfo"))
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("TERMINFO_DIRS")) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return append(dirs, "/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo", "/usr/lib/terminfo")
+}
@@ -0,0 +1,176 @@
+// Package terminfo contains the gritty terminfo entry
+//
+// The entry describes only the sequences that gritty implements. Programs look it up with TERM=gritty,
+// when it isn't installed, gritty uses TERM=xterm-256color.
+package terminfo
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Name is the name of the gritty terminfo entry, it's used as the TERM value
+const Name = "gritty"
+
+// Source is the terminfo source of the gritty entry, tic compiles it
+//
+//go:embed gritty.terminfo
+var Source string
+
+// Kind is the type of the capability
+type Kind int
+
+const (
+	Bool Kind = iota
+	Number
+	String
+)
+
+// Capability is one capability from the terminfo entry
+type Capability struct {
+	Name string
+	Kind Kind
+	// Value is the decimal number for Number capabilities and the decoded string (e.g. "\x1b[A" for \E[A) for String capabilities.
+	// Parametrized strings keep their % codes.
+	Value string
+}
+
+var capabilities = sync.OnceValue(func() []Capability {
+	caps, err := parse(Source)
+	if err != nil {
+		panic(fmt.Sprintf("invalid gritty terminfo source: %v", err))
+	}
+	return caps
+})
+
+// Capabilities returns the capabilities from the gritty entry in the order of the Source
+func Capabilities() []Capability {
+	return capabilities()
+}
+
+// Lookup returns the capability with the name
+func Lookup(name string) (Capability, bool) {
+	for _, c := range Capabilities() {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Capability{}, false
+}
+
+// parse reads the capabilities from a terminfo source with one entry
+// https://man7.org/linux/man-pages/man5/terminfo.5.html
+func parse(src string) ([]Capability, error) {
+	var lines []string
+	for _, l := range strings.Split(src, "\n") {
+		if strings.HasPrefix(l, "#") || strings.TrimSpace(l) == "" {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("the source doesn't contain any entry")
+	}
+	var caps []Capability
+	// the first field is the entry name, so we skip it
+	for _, field := range splitFields(strings.Join(lines[1:], "\n")) {
+		name, value, isString := strings.Cut(field, "=")
+		switch {
+		case isString:
+			decoded, err := decode(value)
+			if err != nil {
+				return nil, fmt.Errorf("capability %s: %w", name, err)
+			}
+			caps = append(caps, Capability{Name: name, Kind: String, Value: decoded})
+		case strings.Contains(field, "#"):
+			name, value, _ := strings.Cut(field, "#")
+			n, err := strconv.ParseInt(value, 0, 32)
+			if err != nil {
+				return nil, fmt.Errorf("capability %s: invalid number %q", name, value)
+			}
+			caps = append(caps, Capability{Name: name, Kind: Number, Value: strconv.Itoa(int(n))})
+		default:
+			caps = append(caps, Capability{Name: field, Kind: Bool})
+		}
+	}
+	return caps, nil
+}
+
+// splitFields splits the capabilities on commas that aren't escaped
+func splitFields(s string) []string {
+	var fields []string
+	var field strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			field.WriteString(s[i : i+2])
+			i++
+		case s[i] == ',':
+			if f := strings.TrimSpace(field.String()); f != "" {
+				fields = append(fields, f)
+			}
+			field.Reset()
+		default:
+			field.WriteByte(s[i])
+		}
+	}
+	if f := strings.TrimSpace(field.String()); f != "" {
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// decode replaces the terminfo escapes (\E, ^X, \n, \177, ...) with the characters they represent
+func decode(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '^' && i+1 < len(s):
+			i++
+			if s[i] == '?' {
+				b.WriteByte(0x7f)
+			} else {
+				b.WriteByte(s[i] & 0x1f)
+			}
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch e := s[i]; e {
+			case 'E', 'e':
+				b.WriteByte(0x1b)
+			case 'n', 'l':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 's':
+				b.WriteByte(' ')
+			case '^', '\\', ',', ':':
+				b.WriteByte(e)
+			case '0', '1', '2', '3':
+				if i+2 >= len(s) {
+					return "", fmt.Errorf("incomplete octal escape in %q", s)
+				}
+				n, err := strconv.ParseUint(s[i:i+3], 8, 8)
+				if err != nil {
+					return "", fmt.Errorf("invalid octal escape in %q", s)
+				}
+				b.WriteByte(byte(n))
+				i += 2
+			default:
+				return "", fmt.Errorf("unknown escape \\%c in %q", e, s)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,66 @@
+package terminfo
+
+import (
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	caps, err := parse(`# comment
+test|test terminal,
+	am, colors#0x100,
+	bel=^G, kbs=\177, cup=\E[%i%p1%d;%p2%dH, Se=\E[0\sq,
+	acsc=\,\,a,`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Capability{
+		{Name: "am", Kind: Bool},
+		{Name: "colors", Kind: Number, Value: "256"},
+		{Name: "bel", Kind: String, Value: "\a"},
+		{Name: "kbs", Kind: String, Value: "\x7f"},
+		{Name: "cup", Kind: String, Value: "\x1b[%i%p1%d;%p2%dH"},
+		{Name: "Se", Kind: String, Value: "\x1b[0 q"},
+		{Name: "acsc", Kind: String, Value: ",,a"},
+	}
+	if !reflect.DeepEqual(caps, expected) {
+		t.Fatalf("expected\n%+v\ngot\n%+v", expected, caps)
+	}
+
+	t.Run("reports invalid escapes", func(t *testing.T) {
+		if _, err := parse("test,\n\tbel=\\q,"); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestLookup(t *testing.T) {
+	if c, ok := Lookup("colors"); !ok || c.Value != "256" {
+		t.Fatalf("the gritty entry should have 256 colors, got %+v", c)
+	}
+	if _, ok := Lookup("missing"); ok {
+		t.Fatal("missing capability shouldn't be found")
+	}
+}
+
+func TestInstall(t *testing.T) {
+	if _, err := exec.LookPath("tic"); err != nil {
+		t.Skip("tic isn't installed")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TERMINFO", "")
+	t.Setenv("TERMINFO_DIRS", "")
+	dir, err := Install()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != filepath.Join(home, ".terminfo") {
+		t.Fatalf("the entry should be installed into ~/.terminfo, but it was installed into %s", dir)
+	}
+	if !Installed() {
+		t.Fatal("the installed entry should be found")
+	}
+}